@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// FromRequest returns the *Context serving r. It is available to net/http
+// handlers and middleware plugged in through WrapHandler, WrapMiddleware or
+// Mount, so they can read the locale, params or session set by the app.
+func FromRequest(r *http.Request) (*Context, bool) {
+	c, ok := r.Context().Value(contextKey{}).(*Context)
+	return c, ok
+}
+
+// stdRequest returns the request with c attached to its context, attaching
+// it on first use only so plain app handlers don't pay for it.
+func (c *Context) stdRequest() *http.Request {
+	if found, ok := FromRequest(c.request); ok && found == c {
+		return c.request
+	}
+	c.request = c.request.WithContext(context.WithValue(c.request.Context(), contextKey{}, c))
+	return c.request
+}
+
+// WrapHandler adapts a net/http handler such as pprof or promhttp so it can
+// be registered on a Router.
+func WrapHandler(h http.Handler) HandlerFunc {
+	return func(c *Context) error {
+		h.ServeHTTP(c.writer, c.stdRequest())
+		return nil
+	}
+}
+
+// WrapMiddleware adapts func(http.Handler) http.Handler middleware. A
+// writer or request replaced by the middleware is handed on to the rest of
+// the chain until the middleware returns. When the rest of the chain fails
+// without writing a response, the error handler writes it there, so it
+// passes through the middleware too; the error is still returned.
+func WrapMiddleware(mw func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			var err error
+			h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tracked := &responseWriter{ResponseWriter: w}
+				c.writer = tracked
+				c.request = r
+				err = next(c)
+				if err != nil && !tracked.committed && c.app != nil {
+					c.app.errorHandlerFor(c)(c, err)
+				}
+			}))
+
+			w, r := c.writer, c.stdRequest()
+			defer func() {
+				c.writer, c.request = w, r
+			}()
+			h.ServeHTTP(w, r)
+			return err
+		}
+	}
+}
+
+// ServeHTTP lets a HandlerFunc be used wherever an http.Handler is expected.
+// Inside an App the existing *Context is reused, with w and r in place of
+// its writer and request until h returns; otherwise a fresh one is created
+// for the request.
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, ok := FromRequest(r)
+	if !ok {
+		c = &Context{}
+	} else {
+		defer func(w http.ResponseWriter, r *http.Request) {
+			c.writer, c.request = w, r
+		}(c.writer, c.request)
+	}
+	c.writer = w
+	c.request = r
+
+	if err := h(c); err != nil {
+		log.Printf("%s %s (%s)", r.Method, r.URL.Path, err.Error())
+	}
+}
+
+// Mount serves h for prefix and everything below it, with the prefix
+// stripped from the request path the way http.StripPrefix does. The
+// middleware of the router group and mws run before h. An *App is mounted
+// as part of the route tree, see App.Mount.
+func (r *Router) Mount(prefix string, h http.Handler, mws ...MiddlewareFunc) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if child, ok := h.(*App); ok {
+		r.mountApp(prefix, child, mws)
+		return
+	}
+
+	handler := WrapHandler(http.StripPrefix(r.prefix+prefix, h))
+
+	r.Any(prefix, handler, mws...)
+	r.Any(prefix+"/*path", handler, mws...)
+}
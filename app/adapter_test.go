@@ -0,0 +1,102 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerFuncRestoresContext(t *testing.T) {
+	a := newTestApp()
+
+	var inner, after string
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+			after = c.Request().URL.Path
+			return err
+		}
+	})
+	a.Route().Mount("/std", HandlerFunc(func(c *Context) error {
+		inner = c.Request().URL.Path
+		return c.Success(nil)
+	}))
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/std/x", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if inner != "/x" {
+		t.Errorf("handler saw %q, want the stripped path /x", inner)
+	}
+	if after != "/std/x" {
+		t.Errorf("middleware saw %q after the handler, want /std/x", after)
+	}
+}
+
+// buffered is a net/http middleware that holds the response back until the
+// handler returned, like compression or ETag middleware do.
+func buffered(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Set("X-Buffered", "1")
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	a := newTestApp()
+
+	var writer http.ResponseWriter
+	var path string
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			writer = c.Writer()
+			err := next(c)
+			if c.Writer() != writer {
+				t.Error("writer of the wrapped middleware still in place")
+			}
+			path = c.Request().URL.Path
+			return err
+		}
+	})
+	a.Use(WrapMiddleware(buffered))
+	a.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.StripPrefix("/api", next)
+	}))
+
+	a.Route().GET("/api/ok", func(c *Context) error {
+		return c.Success("ok")
+	})
+	a.Route().GET("/api/boom", func(c *Context) error {
+		return errors.New("boom")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/ok", http.StatusOK},
+		{"/api/boom", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := do(a, http.MethodGet, tt.path)
+		if w.Code != tt.status || w.Body.Len() == 0 {
+			t.Errorf("%s: status %d with %d bytes, want %d with a body", tt.path, w.Code, w.Body.Len(), tt.status)
+		}
+		if w.Header().Get("X-Buffered") != "1" {
+			t.Errorf("%s: response bypassed the middleware", tt.path)
+		}
+		if path != tt.path {
+			t.Errorf("%s: request after the middleware is %q", tt.path, path)
+		}
+	}
+}
@@ -1,17 +1,22 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"reflect"
 	errpkg "scm/api/app/errors"
 	"scm/api/app/locale"
 	"scm/api/app/validator"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,27 +24,100 @@ type HandlerFunc func(*Context) error
 
 type MiddlewareFunc func(HandlerFunc) HandlerFunc
 
+// ErrorHandlerFunc writes the response for an error returned by the handler
+// chain when nothing has been written yet.
+type ErrorHandlerFunc func(*Context, error)
+
 type routeEntry struct {
-	handler    HandlerFunc
-	middleware []MiddlewareFunc
+	route      *Route
+	paramNames []string
 }
 
 type Router struct {
-	prefix     string
-	routes     map[string]map[string]routeEntry
-	middleware []MiddlewareFunc
+	app          *App
+	host         string
+	prefix       string
+	routes       *tree
+	middleware   []MiddlewareFunc
+	version      *apiVersion
+	errorHandler ErrorHandlerFunc
+	formatter    ResponseFormatter
+	options      []RouteOption
+}
+
+// RouterConfig controls how request paths are normalized before matching.
+type RouterConfig struct {
+	// RedirectTrailingSlash redirects "/users/" to "/users" (and the other
+	// way round) when only the other form is routed: 301 for GET and HEAD,
+	// 308 for every other method so the body is replayed.
+	RedirectTrailingSlash bool
+	// IgnoreTrailingSlash serves the other form directly instead of
+	// redirecting. It takes precedence over RedirectTrailingSlash.
+	IgnoreTrailingSlash bool
+	// CleanPath redirects paths containing "//", "." or ".." elements to
+	// their cleaned form before any route is matched.
+	CleanPath bool
+	// CaseInsensitive matches static segments regardless of ASCII case.
+	// Param values keep the case they were sent with.
+	CaseInsensitive bool
+	// UseRawPath matches against the escaped path when the request has one,
+	// so "/files/a%2Fb" fills ":name" with "a/b" instead of not matching.
+	UseRawPath bool
+}
+
+var DefaultRouterConfig = RouterConfig{
+	RedirectTrailingSlash: true,
+	CleanPath:             true,
 }
 
 type App struct {
-	router *Router
-	mw     []MiddlewareFunc
+	config           RouterConfig
+	router           *Router
+	mw               []MiddlewareFunc
+	routes           []*Route
+	names            map[string]*Route
+	hosts            []*hostRouter
+	mounts           []*mount
+	versions         []*Versions
+	renderers        []renderer
+	formatter        ResponseFormatter
+	conflicts        []error
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	errorHandler     ErrorHandlerFunc
+	lifecycle        lifecycle
+	logger           Logger
+	pool             sync.Pool
 }
 
 func New() *App {
-	r := &Router{
-		routes: make(map[string]map[string]routeEntry),
+	return NewWithConfig(DefaultRouterConfig)
+}
+
+func NewWithConfig(config RouterConfig) *App {
+	app := &App{
+		config:           config,
+		names:            make(map[string]*Route),
+		notFound:         defaultNotFound,
+		methodNotAllowed: defaultMethodNotAllowed,
+		errorHandler:     defaultErrorHandler,
+		renderers:        defaultRenderers(),
+		formatter:        DefaultResponseFormatter,
+		logger:           NewLogger(DefaultLoggerConfig),
+		lifecycle: lifecycle{
+			shutdownTimeout: defaultShutdownTimeout,
+			done:            make(chan struct{}),
+		},
+	}
+	app.router = &Router{
+		app:    app,
+		routes: newTree(config.CaseInsensitive),
+	}
+	app.lifecycle.stopping, app.lifecycle.stop = context.WithCancel(context.Background())
+	app.pool.New = func() any {
+		return &Context{app: app}
 	}
-	return &App{router: r}
+	return app
 }
 
 func (app *App) Route() *Router {
@@ -47,102 +125,362 @@ func (app *App) Route() *Router {
 }
 
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := &Context{writer: w, request: r}
-	method := r.Method
-	path := r.URL.Path
+	ctx := app.pool.Get().(*Context)
+	ctx.reset(w, r)
+	defer app.pool.Put(ctx)
+	path, raw := app.requestPath(r)
 
 	start := time.Now()
 
-	var entry routeEntry
-	var found bool
-	for route, e := range app.router.routes[method] {
-		if params, ok := matchRoute(route, path); ok {
-			ctx.Params = params
-			entry = e
-			found = true
-			break
+	if ctx.requestID != "" {
+		w.Header().Set(requestIDHeader, ctx.requestID)
+	}
+
+	clean := path
+	if app.config.CleanPath {
+		clean = cleanPath(path)
+	}
+
+	var err error
+	if clean != path {
+		redirectPath(ctx.writer, r, clean, raw)
+	} else {
+		err = app.serve(ctx, "", path, raw)
+		if ctx.sse != nil {
+			ctx.sse.Close()
 		}
+		app.handleError(ctx, err)
 	}
 
-	if !found {
-		http.NotFound(w, r)
-		return
+	if app.logger != nil {
+		entry := ctx.accessEntry()
+		entry.Time = start
+		entry.Latency = time.Since(start)
+		entry.Error = err
+		entry.Bytes = ctx.response.size
+		if !ctx.response.committed {
+			// net/http sends an empty 200 for handlers that wrote nothing.
+			entry.Status = http.StatusOK
+		}
+		app.logger.Access(entry)
+	}
+}
+
+// serve matches path against the routes of app and runs the handler behind
+// the app middleware. base is the part of the request path consumed by the
+// apps that app is mounted under, and is only needed for redirects.
+func (app *App) serve(c *Context, base, path string, raw bool) error {
+	method := c.request.Method
+	first := len(c.Params)
+
+	router := app.router
+	if len(app.hosts) > 0 {
+		router = app.matchHost(c.request, &c.Params)
+	}
+
+	routes := router.routes
+	if n := len(c.Params) + routes.maxParams; cap(c.Params) < n {
+		c.Params = append(make(Params, 0, n), c.Params...)
 	}
 
-	final := entry.handler
+	entry := routes.lookup(method, path, &c.Params)
+	if entry == nil && (app.config.RedirectTrailingSlash || app.config.IgnoreTrailingSlash) {
+		alt := toggleTrailingSlash(path)
+		if altEntry := routes.lookup(method, alt, &c.Params); altEntry != nil {
+			if !app.config.IgnoreTrailingSlash {
+				redirectPath(c.writer, c.request, base+alt, raw)
+				return nil
+			}
+			path, entry = alt, altEntry
+		}
+	}
 
-	for i := len(entry.middleware) - 1; i >= 0; i-- {
-		final = entry.middleware[i](final)
+	var route *Route
+	if entry != nil {
+		route = entry.route
+		if raw {
+			params := c.Params[first:]
+			if n := len(params); route.mount != nil && n > 0 && params[n-1].Key == mountParam {
+				// The mounted app matches the rest in its escaped form.
+				params = params[:n-1]
+			}
+			params.unescape()
+		}
+		if route.versioned != nil {
+			// Resolved before the global middleware runs, so it sees the
+			// metadata of the versioned route.
+			route = route.versioned(c)
+		}
+	}
+
+	var final HandlerFunc
+	var middleware []MiddlewareFunc
+
+	c.route = route
+	switch {
+	case route != nil:
+		final = route.handler
+		middleware = route.middleware
+	case entry != nil:
+		// No version up to the requested one defines the route.
+		final = app.notFound
+	default:
+		final = app.unmatched(c.writer, routes, method, path)
 	}
+	c.path = path
+
+	final = chain(final, middleware)
 	// Apply global app middleware
-	for i := len(app.mw) - 1; i >= 0; i-- {
-		final = app.mw[i](final)
+	return chain(final, app.mw)(c)
+}
+
+// handleError hands an error of the handler chain to the error handler of
+// the matched route, or of app, unless a response was already written.
+func (app *App) handleError(c *Context, err error) {
+	if err == nil || c.Committed() {
+		return
 	}
+	app.errorHandlerFor(c)(c, err)
+}
 
-	var message = "success"
-	if err := final(ctx); err != nil {
-		message = err.Error()
+// errorHandlerFor returns the error handler of the route c matched, or the
+// one of app.
+func (app *App) errorHandlerFor(c *Context) ErrorHandlerFunc {
+	if c.route != nil && c.route.errorHandler != nil {
+		return c.route.errorHandler
 	}
+	return app.errorHandler
+}
 
-	stop := time.Now()
-	log.Printf("%s [%d] %s %s (%s) %d milliseconds", ctx.Request().Method,
-		ctx.HttpStatus(),
-		ctx.Request().URL.Path,
-		ctx.Request().RemoteAddr,
-		message, stop.Sub(start).Milliseconds())
+// chain wraps h so that mws run in the order they are listed.
+func chain(h HandlerFunc, mws []MiddlewareFunc) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
 }
 
 func (app *App) Use(mw ...MiddlewareFunc) {
 	app.mw = append(app.mw, mw...)
 }
 
+// NotFound replaces the handler used when no route matches the path. Like
+// MethodNotAllowed it runs behind the global middleware registered with Use.
+func (app *App) NotFound(h HandlerFunc) {
+	app.notFound = h
+}
+
+// MethodNotAllowed replaces the handler used when the path exists under
+// other methods only. The Allow header is already set when h runs.
+func (app *App) MethodNotAllowed(h HandlerFunc) {
+	app.methodNotAllowed = h
+}
+
+// Validate reports every route that could not be registered because it
+// duplicates or is ambiguous with an earlier one, across all groups, hosts,
+// versions and mounted apps. The earlier route keeps serving; Route.Err
+// reports the error of a single route. Start runs Validate and refuses to
+// serve while it fails.
+func (app *App) Validate() error {
+	errs := append([]error{}, app.conflicts...)
+	for _, m := range app.mounts {
+		if err := m.app.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.pattern, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// conflict records a route that could not be registered. It is logged right
+// away so apps served without Start notice it too.
+func (app *App) conflict(err error) {
+	app.conflicts = append(app.conflicts, err)
+	log.Print(err)
+}
+
+// ErrorHandler replaces the handler that turns an error returned by the
+// handler chain into a response. It only runs when the chain returned an
+// error without writing anything, so helpers like BadInput keep working.
+func (app *App) ErrorHandler(h ErrorHandlerFunc) {
+	app.errorHandler = h
+}
+
+// unmatched picks the handler for a request that has no route, setting the
+// Allow header when the path is known under other methods.
+func (app *App) unmatched(w http.ResponseWriter, routes *tree, method, path string) HandlerFunc {
+	allow := routes.allowed(path)
+	if len(allow) == 0 {
+		return app.notFound
+	}
+
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	if method == http.MethodOptions {
+		return defaultOptions
+	}
+	return app.methodNotAllowed
+}
+
 func (r *Router) Group(prefix string, mws ...MiddlewareFunc) *Router {
 	return &Router{
-		prefix:     r.prefix + prefix,
-		routes:     r.routes,
-		middleware: append([]MiddlewareFunc{}, append(r.middleware, mws...)...),
+		app:          r.app,
+		host:         r.host,
+		prefix:       r.prefix + prefix,
+		routes:       r.routes,
+		version:      r.version,
+		middleware:   append([]MiddlewareFunc{}, append(r.middleware, mws...)...),
+		errorHandler: r.errorHandler,
+		formatter:    r.formatter,
+		options:      r.options,
 	}
 }
 
-func (r *Router) handle(method, path string, h HandlerFunc, mws ...MiddlewareFunc) {
-	if r.routes[method] == nil {
-		r.routes[method] = make(map[string]routeEntry)
-	}
+// ErrorHandler overrides App.ErrorHandler for the routes registered on r
+// from now on, including those of groups created from r afterwards.
+func (r *Router) ErrorHandler(h ErrorHandlerFunc) {
+	r.errorHandler = h
+}
+
+func (r *Router) handle(methods []string, path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
 	// Simpan route dengan middleware chain (router group + route)
 	allMiddleware := append([]MiddlewareFunc{}, r.middleware...)
 	allMiddleware = append(allMiddleware, mws...)
-	r.routes[method][path] = routeEntry{
-		handler:    h,
-		middleware: allMiddleware,
+
+	route := &Route{
+		Methods:      methods,
+		Host:         r.host,
+		Pattern:      path,
+		app:          r.app,
+		handler:      h,
+		middleware:   allMiddleware,
+		errorHandler: r.errorHandler,
+		formatter:    r.formatter,
 	}
+	if r.version != nil {
+		route.version = r.version.name
+	}
+	route.With(r.options...)
+	r.app.routes = append(r.app.routes, route)
+
+	var err error
+	if r.version != nil {
+		err = r.version.add(route)
+	} else {
+		err = r.routes.addRoute(route)
+	}
+	if err != nil {
+		route.err = err
+		r.app.conflict(err)
+	}
+	return route
 }
 
 func (r *Router) Use(mws ...MiddlewareFunc) {
 	r.middleware = append(r.middleware, mws...)
 }
 
-func (r *Router) GET(path string, h HandlerFunc, mws ...MiddlewareFunc) {
-	r.handle("GET", r.prefix+path, h, mws...)
+func (r *Router) GET(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"GET"}, r.prefix+path, h, mws...)
+}
+func (r *Router) POST(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"POST"}, r.prefix+path, h, mws...)
+}
+func (r *Router) PUT(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"PUT"}, r.prefix+path, h, mws...)
+}
+func (r *Router) PATCH(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"PATCH"}, r.prefix+path, h, mws...)
+}
+func (r *Router) DELETE(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"DELETE"}, r.prefix+path, h, mws...)
 }
-func (r *Router) POST(path string, h HandlerFunc, mws ...MiddlewareFunc) {
-	r.handle("POST", r.prefix+path, h, mws...)
+func (r *Router) HEAD(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"HEAD"}, r.prefix+path, h, mws...)
+}
+func (r *Router) OPTIONS(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.handle([]string{"OPTIONS"}, r.prefix+path, h, mws...)
+}
+
+// Any registers h for GET, HEAD, POST, PUT, PATCH, DELETE and OPTIONS.
+// TRACE and CONNECT are left out, they echo or tunnel requests and must be
+// registered explicitly through Match.
+func (r *Router) Any(path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	return r.Match(anyMethods, path, h, mws...)
 }
 
-func matchRoute(pattern, path string) (map[string]string, bool) {
-	parts := strings.Split(pattern, "/")
-	pathParts := strings.Split(path, "/")
-	if len(parts) != len(pathParts) {
-		return nil, false
+// Match registers h for each of the given methods.
+func (r *Router) Match(methods []string, path string, h HandlerFunc, mws ...MiddlewareFunc) *Route {
+	upper := make([]string, len(methods))
+	for i, method := range methods {
+		upper[i] = strings.ToUpper(method)
 	}
-	params := make(map[string]string)
-	for i := range parts {
-		if strings.HasPrefix(parts[i], ":") {
-			params[parts[i][1:]] = pathParts[i]
-		} else if parts[i] != pathParts[i] {
-			return nil, false
+	return r.handle(upper, r.prefix+path, h, mws...)
+}
+
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+func defaultNotFound(c *Context) error {
+	return c.NotFound(errpkg.ErrRouteNotFound)
+}
+
+func defaultMethodNotAllowed(c *Context) error {
+	return c.NotAllowed(errpkg.ErrMethodNotAllowed)
+}
+
+// defaultErrorHandler maps *errpkg.Error through its own status, validation
+// errors to 400 and anything else to 500, also when they are wrapped.
+func defaultErrorHandler(c *Context, err error) {
+	var er *errpkg.Error
+	var errs errpkg.Errors
+	if errors.As(err, &er) || errors.As(err, &errs) {
+		c.BadInput(err)
+		return
+	}
+	c.ServerError(err)
+}
+
+// defaultOptions answers OPTIONS for paths that have no explicit OPTIONS
+// route. The Allow header is set by ServeHTTP before the chain runs.
+func defaultOptions(c *Context) error {
+	c.writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params holds matched path params in the order they appear in the pattern.
+type Params []Param
+
+func (ps Params) Get(key string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+func (ps Params) ByName(key string) string {
+	v, _ := ps.Get(key)
+	return v
+}
+
+func (ps Params) unescape() {
+	for i := range ps {
+		if v, err := url.PathUnescape(ps[i].Value); err == nil {
+			ps[i].Value = v
 		}
 	}
-	return params, true
 }
 
 type Session interface {
@@ -152,14 +490,39 @@ type Session interface {
 }
 
 type Context struct {
+	app        *App
+	route      *Route
 	writer     http.ResponseWriter
+	response   responseWriter
 	httpStatus int
 	request    *http.Request
+	requestID  string
+	path       string
+	prefix     string
+	logger     *slog.Logger
+	sse        *EventStream
 	locale     locale.Tag
-	Params     map[string]string
+	version    string
+	values     map[string]any
+	Params     Params
 	Session    Session
 }
 
+// reset prepares a pooled Context for the next request, keeping the Params
+// and values storage. A Context must not be used once its handler returned.
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	clear(c.values)
+	*c = Context{
+		app:       c.app,
+		request:   r,
+		requestID: requestID(r),
+		values:    c.values,
+		Params:    c.Params[:0],
+	}
+	c.response.ResponseWriter = w
+	c.writer = &c.response
+}
+
 func (c *Context) UseLocale(l locale.Tag) {
 	c.locale = l
 }
@@ -168,6 +531,12 @@ func (c *Context) Locale() locale.Tag {
 	return c.locale
 }
 
+// Version returns the API version the request was dispatched to, or "" for
+// routes outside a Versions group.
+func (c *Context) Version() string {
+	return c.version
+}
+
 func (c *Context) JSON(code int, data any) error {
 	c.writer.Header().Set("Content-Type", "application/json")
 	c.writer.WriteHeader(code)
@@ -178,137 +547,76 @@ func (c *Context) Request() *http.Request {
 	return c.request
 }
 
+// Context returns the context of the request. It is cancelled when the
+// client disconnects, so pass it on to queries and outgoing calls.
+func (c *Context) Context() context.Context {
+	return c.request.Context()
+}
+
+// WithContext replaces the request context, e.g. with one carrying a
+// deadline. Middleware and handlers further down the chain see ctx.
+func (c *Context) WithContext(ctx context.Context) {
+	c.request = c.request.WithContext(ctx)
+}
+
 func (c *Context) Writer() http.ResponseWriter {
 	return c.writer
 }
 
+// HttpStatus returns the status sent to the client, or the status set by
+// a helper when nothing has been sent yet.
 func (c *Context) HttpStatus() int {
+	if c.Committed() {
+		return c.response.status
+	}
 	return c.httpStatus
 }
 
 func (c *Context) Success(data any) error {
 	c.httpStatus = http.StatusOK
-	return c.JSON(c.httpStatus, map[string]any{
-		"code": fmt.Sprintf("%d", c.httpStatus),
-		"data": data,
-	})
+	return c.Negotiate(c.httpStatus, c.formatter().Success(c, c.httpStatus, data))
 }
 
 func (c *Context) Unauthorized(err error) error {
-	if er, ok := err.(*errpkg.Error); ok {
-		c.mapError(er)
-
-		return err
-	}
-
-	c.httpStatus = http.StatusUnauthorized
-
-	c.JSON(c.httpStatus, map[string]any{
-		"code": fmt.Sprintf("%d", c.httpStatus),
-		"data": map[string]any{
-			"description": fmt.Sprintf("general unautorized error: %s", err.Error()),
-		},
-	})
-
-	return err
+	return c.fail(http.StatusUnauthorized, err)
 }
 
 func (c *Context) BadInput(err error) error {
-	if er, ok := err.(*errpkg.Error); ok {
-		c.mapError(er)
-
-		return err
-	}
-
-	c.httpStatus = http.StatusBadRequest
-
-	if ers, ok := err.(errpkg.Errors); ok {
-		c.JSON(c.httpStatus, map[string]any{
-			"code": fmt.Sprintf("%d", c.httpStatus),
-			"data": ers.LocalizedError(c.locale),
-		})
-	} else {
-		c.JSON(c.httpStatus, map[string]any{
-			"code": fmt.Sprintf("%d", c.httpStatus),
-			"data": map[string]any{
-				"description": fmt.Sprintf("general input error: %s", err.Error()),
-			},
-		})
-	}
+	return c.fail(http.StatusBadRequest, err)
+}
 
-	return err
+func (c *Context) NotFound(err error) error {
+	return c.fail(http.StatusNotFound, err)
 }
 
 func (c *Context) NotAllowed(err error) error {
-	if er, ok := err.(*errpkg.Error); ok {
-		c.mapError(er)
-
-		return err
-	}
-
-	c.httpStatus = http.StatusMethodNotAllowed
-
-	c.JSON(c.httpStatus, map[string]any{
-		"code": fmt.Sprintf("%d", c.httpStatus),
-		"data": map[string]any{
-			"description": fmt.Sprintf("general not allowed error: %s", err.Error()),
-		},
-	})
-
-	return err
+	return c.fail(http.StatusMethodNotAllowed, err)
 }
 
 func (c *Context) BadGateway(err error) error {
-	if er, ok := err.(*errpkg.Error); ok {
-		c.mapError(er)
-
-		return err
-	}
-
-	c.httpStatus = http.StatusBadGateway
-
-	c.JSON(c.httpStatus, map[string]any{
-		"code": fmt.Sprintf("%d", c.httpStatus),
-		"data": map[string]any{
-			"description": fmt.Sprintf("general bad gateway error: %s", err.Error()),
-		},
-	})
-
-	return err
+	return c.fail(http.StatusBadGateway, err)
 }
 
 func (c *Context) ServerError(err error) error {
-	if er, ok := err.(*errpkg.Error); ok {
-		c.mapError(er)
+	return c.fail(http.StatusInternalServerError, err)
+}
 
-		return err
+// fail writes err with status, or with its own status when err is or wraps
+// an *errpkg.Error, and returns err.
+func (c *Context) fail(status int, err error) error {
+	var er *errpkg.Error
+	if errors.As(err, &er) {
+		status = er.HttpStatus()
 	}
 
-	c.httpStatus = http.StatusInternalServerError
-
-	c.JSON(c.httpStatus, map[string]any{
-		"code": fmt.Sprintf("%d", c.httpStatus),
-		"error": map[string]any{
-			"description": fmt.Sprintf("general server error: %s", err.Error()),
-		},
-	})
+	c.httpStatus = status
+	c.Negotiate(c.httpStatus, c.formatter().Error(c, c.httpStatus, err))
 
 	return err
 }
 
-func (c *Context) mapError(err *errpkg.Error) error {
-	c.httpStatus = err.HttpStatus()
-	return c.JSON(err.HttpStatus(), map[string]any{
-		"code": err.Code(),
-		"data": map[string]any{
-			"description": err.LocalizedError(c.locale),
-		},
-	})
-
-}
-
 func (c *Context) Param(key string) string {
-	return c.Params[key]
+	return c.Params.ByName(key)
 }
 
 func (c *Context) Query(key string) string {
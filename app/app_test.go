@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestApp returns an App with access logging turned off.
+func newTestApp() *App {
+	a := New()
+	a.UseLogger(nil)
+	return a
+}
+
+func do(a *App, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestContextWithContext(t *testing.T) {
+	type key struct{}
+	a := newTestApp()
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			c.WithContext(context.WithValue(c.Context(), key{}, "alice"))
+			return next(c)
+		}
+	})
+
+	var value any
+	var cause error
+	a.Route().GET("/", func(c *Context) error {
+		if c.Context() != c.Request().Context() {
+			t.Error("Context is not the request context")
+		}
+		value = c.Context().Value(key{})
+		cause = c.Context().Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if value != "alice" {
+		t.Errorf("value %v, want the one set through WithContext", value)
+	}
+	if !errors.Is(cause, context.Canceled) {
+		t.Errorf("Err %v, want the cancellation of the client", cause)
+	}
+}
+
+func TestContextReset(t *testing.T) {
+	a := newTestApp()
+
+	var first *Context
+	a.Route().GET("/users/:id", func(c *Context) error {
+		first = c
+		c.Set("user", "alice")
+		c.SSE().Send(Event{Data: "x"})
+		return nil
+	})
+	a.Route().GET("/health", func(c *Context) error {
+		return c.Success(nil)
+	})
+
+	do(a, http.MethodGet, "/users/1")
+	if first == nil {
+		t.Fatal("handler not called")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/health", nil)
+	first.reset(httptest.NewRecorder(), r)
+
+	if _, ok := first.Get("user"); ok {
+		t.Error("values leaked into the next request")
+	}
+	if len(first.Params) != 0 {
+		t.Errorf("Params leaked into the next request: %v", first.Params)
+	}
+	if first.route != nil || first.sse != nil || first.prefix != "" || first.path != "" {
+		t.Error("route state leaked into the next request")
+	}
+	if first.Committed() || first.BytesWritten() != 0 || first.HttpStatus() != 0 {
+		t.Error("response state leaked into the next request")
+	}
+	if first.request != r || first.app != a {
+		t.Error("reset lost the request or app")
+	}
+}
+
+func TestPooledContextsDoNotLeak(t *testing.T) {
+	a := newTestApp()
+
+	a.Route().GET("/users/:id", func(c *Context) error {
+		c.Set("user", c.Param("id"))
+		return c.Success(nil)
+	})
+	a.Route().GET("/files/*path", func(c *Context) error {
+		if _, ok := c.Get("user"); ok {
+			t.Error("value of an earlier request visible")
+		}
+		if len(c.Params) != 1 || c.Param("id") != "" {
+			t.Errorf("params of an earlier request visible: %v", c.Params)
+		}
+		if c.RoutePattern() != "/files/*path" {
+			t.Errorf("route %q", c.RoutePattern())
+		}
+		return c.Success(nil)
+	})
+	a.NotFound(func(c *Context) error {
+		if c.Route() != nil || len(c.Params) != 0 {
+			t.Error("route of an earlier request visible on a 404")
+		}
+		return c.Success(nil)
+	})
+
+	for i := 0; i < 10; i++ {
+		do(a, http.MethodGet, "/users/1")
+		do(a, http.MethodGet, "/files/a/b")
+		do(a, http.MethodGet, "/missing")
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	a := newTestApp()
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			c.Set("user", "alice")
+			return next(c)
+		}
+	})
+	a.Route().GET("/users/:id/posts/:post", func(c *Context) error {
+		c.writer.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users/42/posts/7", nil)
+	r.Header.Set(requestIDHeader, "bench")
+	w := httptest.NewRecorder()
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a.ServeHTTP(w, r)
+		}
+	})
+
+	// Taking the Context out of the pool before every request makes
+	// ServeHTTP allocate a new one, as it did before pooling.
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a.pool.Get()
+			a.ServeHTTP(w, r)
+		}
+	})
+}
+
+func TestMethodHandling(t *testing.T) {
+	a := newTestApp()
+	r := a.Route()
+	r.GET("/users", func(c *Context) error { return c.Success("list") })
+	r.POST("/users", func(c *Context) error { return c.Success("create") })
+	r.OPTIONS("/custom", func(c *Context) error { return c.Success("custom") })
+	r.Any("/any", func(c *Context) error { return c.Success(c.Request().Method) })
+	r.Mount("/std", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{http.MethodGet, "/users", http.StatusOK, ""},
+		{http.MethodHead, "/users", http.StatusOK, ""},
+		{http.MethodPost, "/users", http.StatusOK, ""},
+		{http.MethodPut, "/users", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodDelete, "/users", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodOptions, "/users", http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodOptions, "/custom", http.StatusOK, ""},
+		{http.MethodPatch, "/any", http.StatusOK, ""},
+		{http.MethodTrace, "/any", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT"},
+		{http.MethodConnect, "/any", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT"},
+		{http.MethodTrace, "/std/x", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT"},
+		{http.MethodGet, "/missing", http.StatusNotFound, ""},
+	}
+
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+		if allow := resp.Header.Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+		if tt.method == http.MethodHead && (len(body) != 0 || resp.Header.Get("Content-Type") != "application/json") {
+			t.Errorf("HEAD %s: %d byte body, Content-Type %q", tt.path, len(body), resp.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -84,3 +86,30 @@ func Get(name string) *gorm.DB {
 	}
 	return db
 }
+
+// GetContext is Get bound to ctx, so queries are cancelled together with
+// the request, e.g. database.GetContext(c.Context(), "main").
+func GetContext(ctx context.Context, name string) *gorm.DB {
+	return Get(name).WithContext(ctx)
+}
+
+// CloseAll closes every connection opened with Connect.
+func CloseAll() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var errs []error
+	for name, db := range connections {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close [%s] database: %w", name, err))
+		} else {
+			log.Printf("Closed [%s] database", name)
+		}
+		delete(connections, name)
+	}
+	return errors.Join(errs...)
+}
@@ -28,6 +28,10 @@ var (
 
 	ErrFieldUnsupportedType error
 
+	ErrValidationFailed error
+
+	ErrInvalidWebSocketHandshake error
+
 	ErrFieldInvalidParam = func(param any) error {
 		return registerBuiltinError("ErrFieldInvalidParam", param)
 	}
@@ -44,4 +48,6 @@ func init() {
 	ErrFieldMustBeDate = registerBuiltinError("ErrFieldMustBeDate")
 	ErrFieldMustBeDatetime = registerBuiltinError("ErrFieldMustBeDatetime")
 	ErrFieldUnsupportedType = registerBuiltinError("ErrFieldUnsupportedType")
+	ErrValidationFailed = registerBuiltinError("ErrValidationFailed")
+	ErrInvalidWebSocketHandshake = registerBuiltinError("ErrInvalidWebSocketHandshake")
 }
@@ -0,0 +1,11 @@
+package errors
+
+var (
+	ErrOriginNotAllowed error
+)
+
+func init() {
+	loadYamlFile("403_error_list.yaml")
+
+	ErrOriginNotAllowed = registerBuiltinError("ErrOriginNotAllowed")
+}
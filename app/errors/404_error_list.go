@@ -0,0 +1,11 @@
+package errors
+
+var (
+	ErrRouteNotFound error
+)
+
+func init() {
+	loadYamlFile("404_error_list.yaml")
+
+	ErrRouteNotFound = registerBuiltinError("ErrRouteNotFound")
+}
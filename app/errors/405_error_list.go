@@ -0,0 +1,11 @@
+package errors
+
+var (
+	ErrMethodNotAllowed error
+)
+
+func init() {
+	loadYamlFile("405_error_list.yaml")
+
+	ErrMethodNotAllowed = registerBuiltinError("ErrMethodNotAllowed")
+}
@@ -0,0 +1,11 @@
+package errors
+
+var (
+	ErrNotAcceptable error
+)
+
+func init() {
+	loadYamlFile("406_error_list.yaml")
+
+	ErrNotAcceptable = registerBuiltinError("ErrNotAcceptable")
+}
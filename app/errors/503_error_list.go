@@ -0,0 +1,11 @@
+package errors
+
+var (
+	ErrServiceUnavailable error
+)
+
+func init() {
+	loadYamlFile("503_error_list.yaml")
+
+	ErrServiceUnavailable = registerBuiltinError("ErrServiceUnavailable")
+}
@@ -1,15 +1,39 @@
 package errors
 
 import (
-	"fmt"
+	"embed"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 	"scm/api/app/locale"
 	"strconv"
 
+	stderrors "errors"
+
 	"gopkg.in/yaml.v3"
 )
 
+// yamlDir is where deployed catalogs are read from, relative to the working
+// directory of the binary.
+const yamlDir = "./app/errors/yaml_files"
+
+// yamlFiles holds the built-in catalogs, used when yamlDir does not have the
+// file, e.g. for tests running in the package directory.
+//
+//go:embed yaml_files/*.yaml
+var yamlFiles embed.FS
+
+// readYamlFile reads filename from yamlDir, so deployed catalogs can be
+// changed without a rebuild, and falls back to the embedded copy.
+func readYamlFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(yamlDir, filename))
+	if stderrors.Is(err, fs.ErrNotExist) {
+		return yamlFiles.ReadFile("yaml_files/" + filename)
+	}
+	return data, err
+}
+
 type rawYAMLErrors struct {
 	HttpStatus int                              `yaml:"http_status"`
 	Errors     map[string]map[locale.Tag]string `yaml:"errors"`
@@ -18,7 +42,7 @@ type rawYAMLErrors struct {
 func loadYamlFile(filename string) error {
 	log.Print("load built-in error file: ", filename)
 
-	data, err := os.ReadFile(fmt.Sprintf("./app/errors/yaml_files/%s", filename))
+	data, err := readYamlFile(filename)
 
 	if err != nil {
 		log.Panic(err)
@@ -27,6 +51,28 @@ func loadYamlFile(filename string) error {
 	return collectBuildinErrors(data)
 }
 
+type rawYAMLStatusTexts struct {
+	StatusText map[int]map[locale.Tag]string `yaml:"status_text"`
+}
+
+func loadStatusTextFile(filename string) {
+	log.Print("load built-in status text file: ", filename)
+
+	data, err := readYamlFile(filename)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var raw rawYAMLStatusTexts
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		log.Panic(err)
+	}
+
+	for status, texts := range raw.StatusText {
+		statusTexts[status] = texts
+	}
+}
+
 func collectBuildinErrors(data []byte) error {
 	var raw rawYAMLErrors
 	if err := yaml.Unmarshal(data, &raw); err != nil {
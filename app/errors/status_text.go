@@ -0,0 +1,26 @@
+package errors
+
+import (
+	"net/http"
+	"scm/api/app/locale"
+)
+
+// statusTexts holds the localized reason phrases of HTTP statuses.
+var statusTexts = make(map[int]map[locale.Tag]string)
+
+func init() {
+	loadStatusTextFile("status_text.yaml")
+}
+
+// StatusText returns the reason phrase of status in tag, e.g. "Tidak
+// Ditemukan" for 404 in Bahasa. Statuses or tags missing from the catalog
+// fall back to English and then to http.StatusText.
+func StatusText(status int, tag locale.Tag) string {
+	if text, found := statusTexts[status][tag]; found {
+		return text
+	}
+	if text, found := statusTexts[status][locale.English]; found {
+		return text
+	}
+	return http.StatusText(status)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	errpkg "scm/api/app/errors"
+)
+
+// ResponseFormatter builds the bodies written by Success and the error
+// helpers. The result is encoded by the renderer negotiated for the
+// request.
+type ResponseFormatter interface {
+	Success(c *Context, status int, data any) any
+	Error(c *Context, status int, err error) any
+}
+
+// Envelope is the default ResponseFormatter:
+//
+//	{"code": "200", "data": ...}
+//
+// "code" is always a string. Errors carry the errpkg code of an
+// *errpkg.Error, wrapped or not, or the status for other errors, with the localized
+// description under "data". Validation errors put their localized field
+// map under "data".
+type Envelope struct {
+	// RequestID adds "request_id".
+	RequestID bool
+	// Timestamp adds "timestamp" in RFC 3339.
+	Timestamp bool
+	// Meta, when set, adds "meta" unless it returns an empty map.
+	Meta func(c *Context) map[string]any
+}
+
+var DefaultResponseFormatter ResponseFormatter = Envelope{}
+
+// generalErrors prefixes the description of errors that are not errpkg
+// errors, by status.
+var generalErrors = map[int]string{
+	http.StatusBadRequest:          "general input error",
+	http.StatusUnauthorized:        "general unautorized error",
+	http.StatusNotFound:            "general not found error",
+	http.StatusMethodNotAllowed:    "general not allowed error",
+	http.StatusInternalServerError: "general server error",
+	http.StatusBadGateway:          "general bad gateway error",
+}
+
+func (e Envelope) Success(c *Context, status int, data any) any {
+	return e.body(c, strconv.Itoa(status), data)
+}
+
+func (e Envelope) Error(c *Context, status int, err error) any {
+	var er *errpkg.Error
+	if errors.As(err, &er) {
+		return e.body(c, strconv.Itoa(int(er.Code())), map[string]any{
+			"description": er.LocalizedError(c.locale),
+		})
+	}
+	var errs errpkg.Errors
+	if errors.As(err, &errs) {
+		return e.body(c, strconv.Itoa(status), errs.LocalizedError(c.locale))
+	}
+
+	prefix, ok := generalErrors[status]
+	if !ok {
+		prefix = "general error"
+	}
+	return e.body(c, strconv.Itoa(status), map[string]any{
+		"description": fmt.Sprintf("%s: %s", prefix, err.Error()),
+	})
+}
+
+func (e Envelope) body(c *Context, code string, data any) map[string]any {
+	body := map[string]any{
+		"code": code,
+		"data": data,
+	}
+	if e.RequestID {
+		body["request_id"] = c.requestID
+	}
+	if e.Timestamp {
+		body["timestamp"] = time.Now().Format(time.RFC3339)
+	}
+	if e.Meta != nil {
+		if meta := e.Meta(c); len(meta) > 0 {
+			body["meta"] = meta
+		}
+	}
+	return body
+}
+
+// UseFormatter replaces the ResponseFormatter of app.
+func (app *App) UseFormatter(f ResponseFormatter) {
+	app.formatter = f
+}
+
+// UseFormatter overrides the ResponseFormatter for the routes registered on
+// r from now on, for clients that expect a different shape.
+func (r *Router) UseFormatter(f ResponseFormatter) {
+	r.formatter = f
+}
+
+// formatter returns the ResponseFormatter of the matched route, falling
+// back to the one of the app that owns the route.
+func (c *Context) formatter() ResponseFormatter {
+	if c.route != nil {
+		if c.route.formatter != nil {
+			return c.route.formatter
+		}
+		if c.route.app != nil && c.route.app.formatter != nil {
+			return c.route.app.formatter
+		}
+	}
+	if c.app != nil && c.app.formatter != nil {
+		return c.app.formatter
+	}
+	return DefaultResponseFormatter
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	errpkg "scm/api/app/errors"
+)
+
+func TestEnvelopeCodeIsString(t *testing.T) {
+	a := newTestApp()
+
+	r := a.Route()
+	r.GET("/ok", func(c *Context) error { return c.Success("fine") })
+	r.GET("/errpkg", func(c *Context) error { return errpkg.ErrRouteNotFound })
+	r.GET("/plain", func(c *Context) error { return errors.New("boom") })
+	r.GET("/fields", func(c *Context) error {
+		return errpkg.Errors{"name": errpkg.ErrValidationFailed}
+	})
+	r.GET("/wrapped", func(c *Context) error {
+		return fmt.Errorf("load user: %w", errpkg.ErrRouteNotFound)
+	})
+	r.GET("/wrapped-fields", func(c *Context) error {
+		return fmt.Errorf("bind: %w", errpkg.Errors{"name": errpkg.ErrValidationFailed})
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/ok", http.StatusOK, "200"},
+		{"/errpkg", http.StatusNotFound, "101"},
+		{"/plain", http.StatusInternalServerError, "500"},
+		{"/fields", http.StatusBadRequest, "400"},
+		{"/wrapped", http.StatusNotFound, "101"},
+		{"/wrapped-fields", http.StatusBadRequest, "400"},
+	}
+
+	for _, tt := range tests {
+		w := do(a, http.MethodGet, tt.path)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+		}
+
+		var body struct {
+			Code any `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if body.Code != tt.code {
+			t.Errorf("%s: code %#v, want %q", tt.path, body.Code, tt.code)
+		}
+	}
+}
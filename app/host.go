@@ -0,0 +1,115 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// hostRouter binds a host pattern such as "api.scm.example" or
+// "{tenant}.scm.example" to its own route tree.
+type hostRouter struct {
+	pattern string
+	labels  []string
+	params  int
+	router  *Router
+}
+
+// Host returns the Router for requests whose Host matches pattern. Labels
+// written as "{name}" or ":name" match any single label and are exposed
+// through Context.Param like path params; "*" matches any label without
+// capturing it. Hosts with fewer params are tried first, and requests that
+// match no host fall back to the Router returned by Route.
+func (app *App) Host(pattern string) *Router {
+	for _, h := range app.hosts {
+		if h.pattern == pattern {
+			return h.router
+		}
+	}
+
+	h := &hostRouter{
+		pattern: pattern,
+		router: &Router{
+			app:    app,
+			host:   pattern,
+			routes: newTree(app.config.CaseInsensitive),
+		},
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(pattern, "."), ".") {
+		switch {
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}"):
+			label = ":" + label[1:len(label)-1]
+			h.params++
+		case strings.HasPrefix(label, ":"):
+			h.params++
+		default:
+			label = strings.ToLower(label)
+		}
+		h.labels = append(h.labels, label)
+	}
+
+	app.hosts = append(app.hosts, h)
+	sort.SliceStable(app.hosts, func(i, j int) bool {
+		return app.hosts[i].params < app.hosts[j].params
+	})
+
+	return h.router
+}
+
+// matchHost returns the Router for the request host, appending host params
+// to ps.
+func (app *App) matchHost(r *http.Request, ps *Params) *Router {
+	host := requestHost(r)
+	for _, h := range app.hosts {
+		if h.match(host, ps) {
+			return h.router
+		}
+	}
+	return app.router
+}
+
+func (h *hostRouter) match(host string, ps *Params) bool {
+	base := len(*ps)
+
+	for i, label := range h.labels {
+		value := host
+		if i < len(h.labels)-1 {
+			dot := strings.IndexByte(host, '.')
+			if dot < 0 {
+				*ps = (*ps)[:base]
+				return false
+			}
+			value, host = host[:dot], host[dot+1:]
+		} else if strings.IndexByte(host, '.') >= 0 {
+			*ps = (*ps)[:base]
+			return false
+		}
+
+		switch {
+		case strings.HasPrefix(label, ":"):
+			if value == "" {
+				*ps = (*ps)[:base]
+				return false
+			}
+			*ps = append(*ps, Param{Key: label[1:], Value: value})
+		case label == "*":
+		case label != value:
+			*ps = (*ps)[:base]
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestHost returns the lower-cased request host without port or
+// trailing dot.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	a := newTestApp()
+	a.Host("api.scm.example").GET("/status", func(c *Context) error {
+		return c.Success("api")
+	})
+	a.Host("{tenant}.scm.example").GET("/users/:id", func(c *Context) error {
+		return c.Success(c.Param("tenant") + " " + c.Param("id"))
+	})
+	a.Host("*.cdn.example").GET("/asset", func(c *Context) error {
+		if len(c.Params) != 0 {
+			t.Errorf("* captured %v", c.Params)
+		}
+		return c.Success("cdn")
+	})
+	a.Route().GET("/status", func(c *Context) error {
+		return c.Success("default")
+	})
+
+	tests := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{"api.scm.example", "/status", http.StatusOK, "api"},
+		{"API.Scm.Example:8080", "/status", http.StatusOK, "api"},
+		{"api.scm.example.", "/status", http.StatusOK, "api"},
+		{"acme.scm.example", "/users/7", http.StatusOK, "acme 7"},
+		{"Acme.scm.example:443", "/users/7", http.StatusOK, "acme 7"},
+		{"a.b.scm.example", "/users/7", http.StatusNotFound, ""},
+		{"img.cdn.example", "/asset", http.StatusOK, "cdn"},
+		{"scm.example", "/status", http.StatusOK, "default"},
+		{"other.example", "/status", http.StatusOK, "default"},
+		{"[::1]:8080", "/status", http.StatusOK, "default"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s%s: status %d, want %d", tt.host, tt.path, w.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && !strings.Contains(w.Body.String(), `"`+tt.body+`"`) {
+			t.Errorf("%s%s: body %s, want %q", tt.host, tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"time"
+)
+
+// AccessEntry describes one finished request.
+type AccessEntry struct {
+	Time       time.Time
+	Method     string
+	Host       string
+	Path       string
+	Route      string
+	Status     int
+	Bytes      int64
+	Latency    time.Duration
+	RemoteAddr string
+	UserAgent  string
+	RequestID  string
+	Error      error
+}
+
+// Logger receives the access log of an App. Access is called once per
+// request after the handler chain returns; Request builds the logger
+// returned by Context.Logger from the fields known when the request starts.
+type Logger interface {
+	Access(entry AccessEntry)
+	Request(entry AccessEntry) *slog.Logger
+}
+
+// Field names accepted by LoggerConfig.Fields.
+const (
+	FieldMethod     = "method"
+	FieldHost       = "host"
+	FieldPath       = "path"
+	FieldRoute      = "route"
+	FieldStatus     = "status"
+	FieldBytes      = "bytes"
+	FieldLatency    = "latency"
+	FieldRemoteAddr = "remote_addr"
+	FieldUserAgent  = "user_agent"
+	FieldRequestID  = "request_id"
+	FieldError      = "error"
+)
+
+type LoggerConfig struct {
+	// Format is "json" or "logfmt".
+	Format string
+	Output io.Writer
+	// Fields lists the fields written per entry, in order. Empty means all.
+	Fields []string
+	// ExcludePaths are never logged; entries match on the request path or
+	// on the route pattern, e.g. "/health".
+	ExcludePaths []string
+	// SampleRates maps a route pattern to the fraction of its requests that
+	// is logged, e.g. {"/stock/:sku": 0.1}. Responses with a 5xx status are
+	// always logged.
+	SampleRates map[string]float64
+}
+
+var DefaultLoggerConfig = LoggerConfig{
+	Format: "logfmt",
+	Output: os.Stderr,
+	Fields: []string{
+		FieldMethod, FieldStatus, FieldPath, FieldRoute, FieldBytes, FieldLatency,
+		FieldRemoteAddr, FieldUserAgent, FieldRequestID, FieldError,
+	},
+}
+
+type slogLogger struct {
+	logger  *slog.Logger
+	config  LoggerConfig
+	exclude map[string]bool
+}
+
+// NewLogger returns the log/slog based Logger used by default.
+func NewLogger(config LoggerConfig) Logger {
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+
+	var handler slog.Handler
+	if config.Format == "json" {
+		handler = slog.NewJSONHandler(config.Output, nil)
+	} else {
+		handler = slog.NewTextHandler(config.Output, nil)
+	}
+
+	return NewSlogLogger(slog.New(handler), config)
+}
+
+// NewSlogLogger wraps an existing *slog.Logger, ignoring Format and Output
+// of config.
+func NewSlogLogger(logger *slog.Logger, config LoggerConfig) Logger {
+	if len(config.Fields) == 0 {
+		config.Fields = DefaultLoggerConfig.Fields
+	}
+
+	l := &slogLogger{logger: logger, config: config, exclude: make(map[string]bool)}
+	for _, p := range config.ExcludePaths {
+		l.exclude[p] = true
+	}
+	return l
+}
+
+func (l *slogLogger) Access(entry AccessEntry) {
+	if l.exclude[entry.Path] || (entry.Route != "" && l.exclude[entry.Route]) {
+		return
+	}
+	if rate, ok := l.config.SampleRates[entry.Route]; ok && entry.Status < http.StatusInternalServerError {
+		if mathrand.Float64() >= rate {
+			return
+		}
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case entry.Status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case entry.Status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	l.logger.LogAttrs(context.Background(), level, "request", l.attrs(entry, false)...)
+}
+
+func (l *slogLogger) Request(entry AccessEntry) *slog.Logger {
+	attrs := l.attrs(entry, true)
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return l.logger.With(args...)
+}
+
+// attrs converts entry into the configured fields. With start set only the
+// fields known before the handler runs are included.
+func (l *slogLogger) attrs(entry AccessEntry, start bool) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(l.config.Fields))
+	for _, field := range l.config.Fields {
+		switch field {
+		case FieldMethod:
+			attrs = append(attrs, slog.String(field, entry.Method))
+		case FieldHost:
+			attrs = append(attrs, slog.String(field, entry.Host))
+		case FieldPath:
+			attrs = append(attrs, slog.String(field, entry.Path))
+		case FieldRoute:
+			attrs = append(attrs, slog.String(field, entry.Route))
+		case FieldRemoteAddr:
+			attrs = append(attrs, slog.String(field, entry.RemoteAddr))
+		case FieldUserAgent:
+			attrs = append(attrs, slog.String(field, entry.UserAgent))
+		case FieldRequestID:
+			attrs = append(attrs, slog.String(field, entry.RequestID))
+		}
+
+		if start {
+			continue
+		}
+
+		switch field {
+		case FieldStatus:
+			attrs = append(attrs, slog.Int(field, entry.Status))
+		case FieldBytes:
+			attrs = append(attrs, slog.Int64(field, entry.Bytes))
+		case FieldLatency:
+			attrs = append(attrs, slog.Duration(field, entry.Latency))
+		case FieldError:
+			if entry.Error != nil {
+				attrs = append(attrs, slog.String(field, entry.Error.Error()))
+			}
+		}
+	}
+	return attrs
+}
+
+// UseLogger replaces the access logger. Passing nil turns access logging
+// off; Context.Logger then falls back to slog.Default.
+func (app *App) UseLogger(l Logger) {
+	app.logger = l
+}
+
+// Logger returns a logger tagged with the fields of the current request.
+func (c *Context) Logger() *slog.Logger {
+	if c.logger == nil {
+		if c.app != nil && c.app.logger != nil {
+			c.logger = c.app.logger.Request(c.accessEntry())
+		} else {
+			c.logger = slog.Default()
+		}
+	}
+	return c.logger
+}
+
+// RequestID returns the id of the request, taken from the X-Request-Id
+// header or generated when the client sent none.
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+func (c *Context) accessEntry() AccessEntry {
+	entry := AccessEntry{
+		Method:     c.request.Method,
+		Host:       c.request.Host,
+		Path:       c.request.URL.Path,
+		Status:     c.HttpStatus(),
+		RemoteAddr: c.request.RemoteAddr,
+		UserAgent:  c.request.UserAgent(),
+		RequestID:  c.requestID,
+	}
+	entry.Route = c.RoutePattern()
+	return entry
+}
+
+const requestIDHeader = "X-Request-Id"
+
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
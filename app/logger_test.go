@@ -0,0 +1,160 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newLoggedApp returns an App logging to buf with config.
+func newLoggedApp(buf *bytes.Buffer, config LoggerConfig) *App {
+	config.Output = buf
+	a := New()
+	a.UseLogger(NewLogger(config))
+
+	r := a.Route()
+	r.GET("/users/:id", func(c *Context) error { return c.Success(c.Param("id")) })
+	r.GET("/health", func(c *Context) error { return c.Success(nil) })
+	r.GET("/stock/:sku", func(c *Context) error {
+		if c.Param("sku") == "broken" {
+			return errors.New("db down")
+		}
+		return c.Success(nil)
+	})
+	return a
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	a := newLoggedApp(&buf, LoggerConfig{
+		Format: "json",
+		Fields: []string{FieldMethod, FieldStatus, FieldRoute, FieldError},
+	})
+
+	do(a, http.MethodGet, "/users/42")
+	do(a, http.MethodGet, "/stock/broken")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines: %s", len(lines), buf.String())
+	}
+
+	want := []map[string]any{
+		{"level": "INFO", "msg": "request", "method": "GET", "status": 200.0, "route": "/users/:id"},
+		{"level": "ERROR", "msg": "request", "method": "GET", "status": 500.0, "route": "/stock/:sku", "error": "db down"},
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		delete(got, "time")
+		if len(got) != len(want[i]) {
+			t.Errorf("fields %v, want %v", got, want[i])
+		}
+		for k, v := range want[i] {
+			if got[k] != v {
+				t.Errorf("%s = %v, want %v", k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestLoggerLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	a := newLoggedApp(&buf, LoggerConfig{
+		Format: "logfmt",
+		Fields: []string{FieldMethod, FieldPath, FieldStatus},
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	r.Header.Set(requestIDHeader, "abc")
+	a.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	if !strings.Contains(line, "level=INFO msg=request method=GET path=/users/7 status=200\n") {
+		t.Errorf("logged %q", line)
+	}
+	if strings.Contains(line, "request_id") || strings.Contains(line, "latency") {
+		t.Errorf("unselected fields logged: %q", line)
+	}
+}
+
+func TestLoggerFilters(t *testing.T) {
+	var buf bytes.Buffer
+	a := newLoggedApp(&buf, LoggerConfig{
+		Format:       "logfmt",
+		Fields:       []string{FieldPath, FieldStatus},
+		ExcludePaths: []string{"/health", "/users/:id"},
+		SampleRates:  map[string]float64{"/stock/:sku": 0},
+	})
+
+	for _, path := range []string{"/health", "/users/1", "/stock/a", "/stock/b", "/stock/broken"} {
+		do(a, http.MethodGet, path)
+	}
+
+	// Excluded by path and by route; sampled out except for the 5xx.
+	if got := buf.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, "path=/stock/broken status=500") {
+		t.Errorf("logged %q", got)
+	}
+}
+
+func TestLoggerCleanPathRedirect(t *testing.T) {
+	logger := &captureLogger{}
+	a := New()
+	a.UseLogger(logger)
+	a.Route().GET("/users/:id", func(c *Context) error { return c.Success(nil) })
+
+	w := do(a, http.MethodGet, "/users//1")
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("status %d", w.Code)
+	}
+	if len(logger.entries) != 1 {
+		t.Fatalf("logged %d entries", len(logger.entries))
+	}
+	if entry := logger.entries[0]; entry.Status != http.StatusMovedPermanently || entry.Path != "/users//1" {
+		t.Errorf("logged %d %s", entry.Status, entry.Path)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	a := newLoggedApp(&buf, LoggerConfig{Format: "json"})
+	a.Route().GET("/orders/:id", func(c *Context) error {
+		c.Logger().Info("loading order", "order", c.Param("id"))
+		return c.Success(nil)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/orders/9", nil)
+	r.Header.Set(requestIDHeader, "req-1")
+	a.ServeHTTP(httptest.NewRecorder(), r)
+
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	var got map[string]any
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	want := map[string]any{"msg": "loading order", "order": "9", "request_id": "req-1", "route": "/orders/:id", "path": "/orders/9"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["status"]; ok {
+		t.Error("status logged before the response")
+	}
+
+	// Without an access logger it falls back to slog.Default.
+	a = newTestApp()
+	a.Route().GET("/", func(c *Context) error {
+		if c.Logger() == nil {
+			t.Error("no logger")
+		}
+		return nil
+	})
+	do(a, http.MethodGet, "/")
+}
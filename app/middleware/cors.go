@@ -16,7 +16,7 @@ type CORSConfig struct {
 
 var DefaultCORSConfig = CORSConfig{
 	AllowOrigins:     []string{"*"},
-	AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+	AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 	AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 	AllowCredentials: false,
 }
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"scm/api/app"
+)
+
+func serve(a *app.App, method, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Origin", "https://web.example")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w
+}
+
+func TestUnmatchedRunsGlobalMiddleware(t *testing.T) {
+	a := app.New()
+	a.UseLogger(nil)
+	a.Use(Recover, CORS(DefaultCORSConfig), LocaleWrapper)
+	a.Route().GET("/users", func(c *app.Context) error { return c.Success(nil) })
+
+	tests := []struct {
+		method      string
+		target      string
+		status      int
+		allow       string
+		description string
+	}{
+		{http.MethodGet, "/missing", http.StatusNotFound, "", "Resource Not Found"},
+		{http.MethodGet, "/missing?lang=id", http.StatusNotFound, "", "Sumber daya tidak ditemukan"},
+		{http.MethodPost, "/users", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", "Method Not Allowed"},
+		{http.MethodPost, "/users?lang=id", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", "Metode tidak diizinkan"},
+		// CORS answers preflights itself, for unknown paths too.
+		{http.MethodOptions, "/missing", http.StatusNoContent, "", ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(a, tt.method, tt.target)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.status)
+			continue
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "https://web.example" {
+			t.Errorf("%s %s: CORS headers missing", tt.method, tt.target)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.target, allow, tt.allow)
+		}
+		if tt.description == "" {
+			continue
+		}
+
+		var body struct {
+			Code string `json:"code"`
+			Data struct {
+				Description string `json:"description"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.target, err)
+		}
+		if body.Code != "101" || body.Data.Description != tt.description {
+			t.Errorf("%s %s: body %s, want %q", tt.method, tt.target, w.Body.String(), tt.description)
+		}
+	}
+}
+
+func TestUnmatchedHooks(t *testing.T) {
+	a := app.New()
+	a.UseLogger(nil)
+	a.Use(Recover, CORS(DefaultCORSConfig))
+	a.Route().GET("/users", func(c *app.Context) error { return c.Success(nil) })
+
+	a.NotFound(func(c *app.Context) error {
+		if c.Request().URL.Path == "/panic" {
+			panic("not found hook failed")
+		}
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no such page"})
+	})
+	a.MethodNotAllowed(func(c *app.Context) error {
+		return c.JSON(http.StatusMethodNotAllowed, map[string]string{"allow": c.Writer().Header().Get("Allow")})
+	})
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/missing", http.StatusNotFound, `{"error":"no such page"}`},
+		{http.MethodDelete, "/users", http.StatusMethodNotAllowed, `{"allow":"GET, HEAD, OPTIONS"}`},
+		{http.MethodGet, "/panic", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		w := serve(a, tt.method, tt.target)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.status)
+		}
+		if tt.body != "" && w.Body.String() != tt.body+"\n" {
+			t.Errorf("%s %s: body %q, want %q", tt.method, tt.target, w.Body.String(), tt.body)
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") == "" {
+			t.Errorf("%s %s: CORS headers missing", tt.method, tt.target)
+		}
+	}
+}
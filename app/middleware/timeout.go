@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"scm/api/app"
+	errpkg "scm/api/app/errors"
+)
+
+// Timeout cancels the request context after timeout. Handlers stop by
+// watching ctx.Context(), e.g. through database.GetContext; when the deadline
+// passed before anything was written the request fails with
+// errpkg.ErrServiceUnavailable, the 503 http.TimeoutHandler answers with.
+// The previous context is restored once the rest of the chain returned, so
+// outer middleware and the error handler are not cut short.
+func Timeout(timeout time.Duration) app.MiddlewareFunc {
+	return func(next app.HandlerFunc) app.HandlerFunc {
+		return func(ctx *app.Context) error {
+			parent := ctx.Context()
+			c, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+			ctx.WithContext(c)
+			defer ctx.WithContext(parent)
+
+			err := next(ctx)
+			if errors.Is(c.Err(), context.DeadlineExceeded) && !ctx.Committed() {
+				return errpkg.ErrServiceUnavailable
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"scm/api/app"
+)
+
+func TestTimeout(t *testing.T) {
+	a := app.New()
+	a.UseLogger(nil)
+
+	var outerErr, handlerErr error
+	a.Use(func(next app.HandlerFunc) app.HandlerFunc {
+		return func(c *app.Context) error {
+			err := next(c)
+			outerErr = c.Context().Err()
+			return err
+		}
+	})
+	a.ErrorHandler(func(c *app.Context, err error) {
+		handlerErr = c.Context().Err()
+		c.ServerError(err)
+	})
+
+	r := a.Route().Group("", Timeout(20*time.Millisecond))
+	r.GET("/fast", func(c *app.Context) error {
+		return c.Success(nil)
+	})
+	r.GET("/slow", func(c *app.Context) error {
+		<-c.Context().Done()
+		return c.Context().Err()
+	})
+	r.GET("/committed", func(c *app.Context) error {
+		c.Writer().WriteHeader(http.StatusAccepted)
+		<-c.Context().Done()
+		return nil
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/fast", http.StatusOK},
+		{"/slow", http.StatusServiceUnavailable},
+		{"/committed", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		outerErr, handlerErr = nil, nil
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		if outerErr != nil || handlerErr != nil {
+			t.Errorf("%s: context after Timeout returned: middleware %v, error handler %v", tt.path, outerErr, handlerErr)
+		}
+	}
+}
+
+func TestTimeoutKeepsParentDeadline(t *testing.T) {
+	a := app.New()
+	a.UseLogger(nil)
+
+	var deadline time.Time
+	a.Route().GET("/", func(c *app.Context) error {
+		deadline, _ = c.Context().Deadline()
+		return c.Success(nil)
+	}, Timeout(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	a.ServeHTTP(httptest.NewRecorder(), r)
+
+	if want, _ := ctx.Deadline(); !deadline.Equal(want) {
+		t.Errorf("deadline %v, want the earlier one of the request %v", deadline, want)
+	}
+}
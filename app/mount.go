@@ -0,0 +1,131 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountParam names the catch-all capturing the path below a mounted App.
+const mountParam = "mountpath"
+
+type mount struct {
+	pattern    string
+	host       string
+	app        *App
+	middleware []MiddlewareFunc
+}
+
+// Mount serves child under prefix. Requests below prefix are routed by
+// child with the prefix stripped, from Context.Request too, and run through
+// the middleware of app, then of child, then of the matched child route.
+// Unmatched paths get the NotFound and MethodNotAllowed handlers of child,
+// and errors are handled by its ErrorHandler. Routes and URL include the
+// routes of child, and Validate its conflicts.
+//
+//	billing := app.New()
+//	billing.Use(billingAuth)
+//	billing.Route().GET("/invoices/:id", showInvoice).Name("invoices.show")
+//	a.Mount("/billing", billing)
+//
+// The logger, lifecycle hooks and RouterConfig path cleaning of child are
+// not used; the parent app owns the request.
+func (app *App) Mount(prefix string, child *App) {
+	app.router.Mount(prefix, child)
+}
+
+func (r *Router) mountApp(prefix string, child *App, mws []MiddlewareFunc) {
+	m := &mount{
+		pattern:    r.prefix + prefix,
+		host:       r.host,
+		app:        child,
+		middleware: append(append([]MiddlewareFunc{}, r.middleware...), mws...),
+	}
+	r.app.mounts = append(r.app.mounts, m)
+
+	patterns := []string{m.pattern, m.pattern + "/*" + mountParam}
+	if m.pattern == "" {
+		patterns = patterns[1:]
+	}
+
+	for _, pattern := range patterns {
+		err := r.routes.addRoute(&Route{
+			Methods:    anyMethods,
+			Host:       r.host,
+			Pattern:    pattern,
+			app:        r.app,
+			mount:      child,
+			handler:    m.serve,
+			middleware: m.middleware,
+		})
+		if err != nil {
+			r.app.conflict(err)
+		}
+	}
+}
+
+func (m *mount) serve(c *Context) error {
+	rest := "/"
+	if n := len(c.Params); n > 0 && c.Params[n-1].Key == mountParam {
+		rest += c.Params[n-1].Value
+		c.Params = c.Params[:n-1]
+	}
+	base := strings.TrimSuffix(c.path, rest)
+	_, raw := c.app.requestPath(c.request)
+
+	// The child sees the request path without the prefix, the way
+	// http.StripPrefix hands it to handlers mounted below the child.
+	req := c.request
+	defer func() { c.request = req }()
+	c.request = stripRequest(req, rest, raw)
+
+	// Until the child matches, no route of the child serves the request.
+	c.route = nil
+	c.prefix = joinPattern(c.prefix, m.pattern)
+	err := m.app.serve(c, base, rest, raw)
+	m.app.handleError(c, err)
+	return err
+}
+
+// stripRequest returns a shallow copy of r for path, the escaped form when
+// raw is set.
+func stripRequest(r *http.Request, path string, raw bool) *http.Request {
+	u := *r.URL
+	if raw {
+		u.RawPath = path
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			u.Path = unescaped
+		}
+	} else {
+		u.Path = path
+		u.RawPath = ""
+	}
+
+	stripped := new(http.Request)
+	*stripped = *r
+	stripped.URL = &u
+	return stripped
+}
+
+// named finds the route registered as name in app or in the apps mounted
+// below it, returning the mount prefix it is reached through.
+func (app *App) named(name string) (string, *Route) {
+	if route, ok := app.names[name]; ok {
+		return "", route
+	}
+	for _, m := range app.mounts {
+		if prefix, route := m.app.named(name); route != nil {
+			return joinPattern(m.pattern, prefix), route
+		}
+	}
+	return "", nil
+}
+
+// joinPattern appends pattern to a mount prefix, serving the root of a
+// mounted app at the prefix itself.
+func joinPattern(prefix, pattern string) string {
+	if prefix != "" && pattern == "/" {
+		return prefix
+	}
+	return prefix + pattern
+}
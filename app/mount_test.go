@@ -0,0 +1,69 @@
+package app
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+type captureLogger struct {
+	entries []AccessEntry
+}
+
+func (l *captureLogger) Access(entry AccessEntry) {
+	l.entries = append(l.entries, entry)
+}
+
+func (l *captureLogger) Request(AccessEntry) *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestMount(t *testing.T) {
+	logger := &captureLogger{}
+	a := New()
+	a.UseLogger(logger)
+
+	child := New()
+	child.Route().GET("/invoices/:id", func(c *Context) error {
+		return c.Success(c.Request().URL.Path + " " + c.Param("id"))
+	})
+	child.Route().Mount("/std", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	a.Mount("/billing", child)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		route  string
+	}{
+		{"/billing/invoices/7", http.StatusOK, `{"code":"200","data":"/invoices/7 7"}` + "\n", "/billing/invoices/:id"},
+		{"/billing/std/x", http.StatusOK, "/x", "/billing/std/*path"},
+		{"/billing/invoices/7/", http.StatusMovedPermanently, "", ""},
+		{"/billing/missing", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		logger.entries = nil
+		w := do(a, http.MethodGet, tt.path)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: body %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+		if len(logger.entries) != 1 {
+			t.Fatalf("%s: %d access entries", tt.path, len(logger.entries))
+		}
+		if entry := logger.entries[0]; entry.Route != tt.route || entry.Path != tt.path {
+			t.Errorf("%s: logged route %q path %q, want %q", tt.path, entry.Route, entry.Path, tt.route)
+		}
+	}
+
+	if w := do(a, http.MethodGet, "/billing/invoices/7/"); w.Header().Get("Location") != "/billing/invoices/7" {
+		t.Errorf("redirected to %q", w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// requestPath returns the path used for matching and whether it is the
+// escaped form taken from URL.RawPath.
+func (app *App) requestPath(r *http.Request) (string, bool) {
+	if app.config.UseRawPath && r.URL.RawPath != "" {
+		return r.URL.RawPath, true
+	}
+	return r.URL.Path, false
+}
+
+// cleanPath is path.Clean that keeps a trailing slash and always returns
+// an absolute path.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	clean := path.Clean(p)
+	if p[len(p)-1] == '/' && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+func toggleTrailingSlash(p string) string {
+	if p == "/" {
+		return p
+	}
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// redirectPath sends the client to p, keeping the query string. GET and
+// HEAD get a 301; other methods get a 308 so clients resend the body.
+func redirectPath(w http.ResponseWriter, r *http.Request, p string, raw bool) {
+	u := *r.URL
+	if raw {
+		u.RawPath = p
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path = unescaped
+		}
+	} else {
+		u.Path = p
+		u.RawPath = ""
+	}
+
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, u.RequestURI(), code)
+}
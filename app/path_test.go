@@ -0,0 +1,82 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPathApp(config RouterConfig) *App {
+	a := NewWithConfig(config)
+	a.UseLogger(nil)
+
+	r := a.Route()
+	r.GET("/users", func(c *Context) error { return c.Success("users") })
+	r.POST("/users", func(c *Context) error { return c.Success("created") })
+	r.GET("/users/:id", func(c *Context) error { return c.Success("user " + c.Param("id")) })
+	r.GET("/docs/", func(c *Context) error { return c.Success("docs") })
+	r.GET("/files/:name", func(c *Context) error { return c.Success("file " + c.Param("name")) })
+	return a
+}
+
+func TestPathHandling(t *testing.T) {
+	rawRedirect := DefaultRouterConfig
+	rawRedirect.UseRawPath = true
+
+	configs := map[string]RouterConfig{
+		"default": DefaultRouterConfig,
+		"ignore":  {IgnoreTrailingSlash: true, RedirectTrailingSlash: true},
+		"none":    {},
+		"raw":     rawRedirect,
+	}
+
+	tests := []struct {
+		config   string
+		method   string
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{"default", http.MethodGet, "/users/", http.StatusMovedPermanently, "/users", ""},
+		{"default", http.MethodHead, "/users/", http.StatusMovedPermanently, "/users", ""},
+		{"default", http.MethodPost, "/users/", http.StatusPermanentRedirect, "/users", ""},
+		{"default", http.MethodGet, "/users/?page=2", http.StatusMovedPermanently, "/users?page=2", ""},
+		{"default", http.MethodGet, "/docs", http.StatusMovedPermanently, "/docs/", ""},
+		{"default", http.MethodGet, "/x/../users", http.StatusMovedPermanently, "/users", ""},
+		{"default", http.MethodPost, "/users//", http.StatusPermanentRedirect, "/users/", ""},
+		{"default", http.MethodGet, "/users/./1", http.StatusMovedPermanently, "/users/1", ""},
+		{"default", http.MethodGet, "/files/a%2Fb", http.StatusNotFound, "", ""},
+		{"default", http.MethodGet, "/files/a%20b", http.StatusOK, "", "file a b"},
+
+		{"ignore", http.MethodGet, "/users/", http.StatusOK, "", "users"},
+		{"ignore", http.MethodPost, "/users/", http.StatusOK, "", "created"},
+		{"ignore", http.MethodGet, "/docs", http.StatusOK, "", "docs"},
+
+		{"none", http.MethodGet, "/users/", http.StatusNotFound, "", ""},
+		{"none", http.MethodGet, "/users//1", http.StatusNotFound, "", ""},
+
+		{"raw", http.MethodGet, "/files/a%2Fb", http.StatusOK, "", "file a/b"},
+		{"raw", http.MethodGet, "/files/a%2Fb%20c", http.StatusOK, "", "file a/b c"},
+		{"raw", http.MethodGet, "/files/a%2Fb/", http.StatusMovedPermanently, "/files/a%2Fb", ""},
+	}
+
+	for _, tt := range tests {
+		a := newPathApp(configs[tt.config])
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+		name := tt.config + " " + tt.method + " " + tt.target
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", name, w.Code, tt.status)
+			continue
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: Location %q, want %q", name, loc, tt.location)
+		}
+		if tt.body != "" && !strings.Contains(w.Body.String(), `"`+tt.body+`"`) {
+			t.Errorf("%s: body %s, want %q", name, w.Body.String(), tt.body)
+		}
+	}
+}
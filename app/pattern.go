@@ -0,0 +1,204 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// paramConstraint restricts the values a path param accepts, e.g.
+// ":id<int>" or ":code<[A-Z]{3}>". Requests whose segment does not match
+// never reach the handler.
+type paramConstraint struct {
+	source string
+	match  func(string) bool
+}
+
+var (
+	paramConstraints = map[string]func(string) bool{
+		"int":   regexp.MustCompile(`^-?\d+$`).MatchString,
+		"uint":  regexp.MustCompile(`^\d+$`).MatchString,
+		"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+		"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	}
+	constraintMu sync.RWMutex
+)
+
+// overlappingConstraints lists the built-in constraints some segment
+// satisfies two of, e.g. "7" for int and uint.
+var overlappingConstraints = map[[2]string]bool{
+	{"int", "uint"}:    true,
+	{"alnum", "int"}:   true,
+	{"alnum", "uint"}:  true,
+	{"alnum", "alpha"}: true,
+}
+
+// overlapping reports whether two constrained param vertices at the same
+// position can both take a segment, in which case the route that matches
+// depends on registration order. An unconstrained param always loses to a
+// constrained one and never overlaps. Whether a regular expression or a
+// registered constraint overlaps another one is not known; they are tried
+// in registration order.
+func overlapping(a, b *paramConstraint) bool {
+	if a == nil || b == nil || a.source == b.source {
+		return false
+	}
+	pair := [2]string{a.source, b.source}
+	if pair[0] > pair[1] {
+		pair[0], pair[1] = pair[1], pair[0]
+	}
+	return overlappingConstraints[pair]
+}
+
+// RegisterParamConstraint makes name usable as ":param<name>" in route
+// patterns. Anything that is not a registered name is compiled as a regular
+// expression matched against the whole segment.
+func RegisterParamConstraint(name string, fn func(string) bool) {
+	constraintMu.Lock()
+	defer constraintMu.Unlock()
+	paramConstraints[name] = fn
+}
+
+func newParamConstraint(source string) *paramConstraint {
+	constraintMu.RLock()
+	fn, ok := paramConstraints[source]
+	constraintMu.RUnlock()
+
+	if !ok {
+		re, err := regexp.Compile("^(?:" + source + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("app: invalid param constraint <%s>: %v", source, err))
+		}
+		fn = re.MatchString
+	}
+
+	return &paramConstraint{source: source, match: fn}
+}
+
+// parseParam splits a param token without its leading ':' into its name
+// and optional constraint.
+func parseParam(token string) (string, *paramConstraint) {
+	i := strings.IndexByte(token, '<')
+	if i < 0 {
+		return token, nil
+	}
+	if !strings.HasSuffix(token, ">") {
+		panic(fmt.Sprintf("app: unterminated param constraint in %q", token))
+	}
+	return token[:i], newParamConstraint(token[i+1 : len(token)-1])
+}
+
+// segmentEnd returns the index of the next '/' in pattern that is not part
+// of a constraint, or len(pattern).
+func segmentEnd(pattern string) int {
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case '/':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(pattern)
+}
+
+// splitSegments cuts pattern before every '/' that is not part of a
+// constraint, so "/a/:b<x/y>" yields "/a" and "/:b<x/y>".
+func splitSegments(pattern string) []string {
+	var segs []string
+	for len(pattern) > 0 {
+		end := segmentEnd(pattern[1:]) + 1
+		if pattern[0] != '/' {
+			end = segmentEnd(pattern)
+		}
+		segs = append(segs, pattern[:end])
+		pattern = pattern[end:]
+	}
+	return segs
+}
+
+// expandPattern turns a user supplied pattern into the list of patterns the
+// tree understands: Go 1.22 style "{id}" and "{path...}" become ":id" and
+// "*path", and every optional ":param?" doubles the list into a variant with
+// and without that segment.
+func expandPattern(pattern string) []string {
+	pattern = normalizePattern(pattern)
+
+	variants := []string{""}
+	for _, seg := range splitSegments(pattern) {
+		if strings.HasPrefix(seg, "/:") && strings.HasSuffix(seg, "?") {
+			seg = strings.TrimSuffix(seg, "?")
+			for i, n := 0, len(variants); i < n; i++ {
+				variants = append(variants, variants[i]+seg)
+			}
+			continue
+		}
+
+		for i := range variants {
+			variants[i] += seg
+		}
+	}
+
+	for i, v := range variants {
+		if v == "" {
+			variants[i] = "/"
+		}
+	}
+	return variants
+}
+
+func normalizePattern(pattern string) string {
+	if !strings.Contains(pattern, "{") {
+		return pattern
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '<':
+			end := closing(pattern, i, '<', '>')
+			b.WriteString(pattern[i : end+1])
+			i = end + 1
+		case '{':
+			end := closing(pattern, i, '{', '}')
+			inner := pattern[i+1 : end]
+			switch {
+			case inner == "$":
+			case strings.HasSuffix(inner, "..."):
+				b.WriteByte('*')
+				b.WriteString(strings.TrimSuffix(inner, "..."))
+			default:
+				b.WriteByte(':')
+				b.WriteString(inner)
+			}
+			i = end + 1
+		default:
+			b.WriteByte(pattern[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func closing(pattern string, start int, open, close byte) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	panic(fmt.Sprintf("app: unbalanced %q in route pattern %q", open, pattern))
+}
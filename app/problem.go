@@ -0,0 +1,137 @@
+package app
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	errpkg "scm/api/app/errors"
+	"scm/api/app/locale"
+)
+
+// Problem is an RFC 9457 problem details body. Negotiate sends it as
+// application/problem+json, or application/problem+xml with the XML
+// renderer.
+type Problem struct {
+	Type     string          `json:"type" xml:"type" yaml:"type"`
+	Title    string          `json:"title" xml:"title" yaml:"title"`
+	Status   int             `json:"status" xml:"status" yaml:"status"`
+	Detail   string          `json:"detail,omitempty" xml:"detail,omitempty" yaml:"detail,omitempty"`
+	Instance string          `json:"instance,omitempty" xml:"instance,omitempty" yaml:"instance,omitempty"`
+	Code     errpkg.ErrCode  `json:"code,omitempty" xml:"code,omitempty" yaml:"code,omitempty"`
+	Errors   []ProblemDetail `json:"errors,omitempty" xml:"errors>error,omitempty" yaml:"errors,omitempty"`
+}
+
+// ProblemDetail is one invalid field of a validation problem. Pointer is a
+// JSON Pointer (RFC 6901) into the request body, e.g. "/address/city".
+type ProblemDetail struct {
+	Pointer string         `json:"pointer" xml:"pointer" yaml:"pointer"`
+	Detail  string         `json:"detail" xml:"detail" yaml:"detail"`
+	Code    errpkg.ErrCode `json:"code,omitempty" xml:"code,omitempty" yaml:"code,omitempty"`
+}
+
+// MarshalXML writes p as the <problem> element of RFC 9457 appendix B.
+func (p *Problem) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	type problem Problem
+	start := xml.StartElement{Name: xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}}
+	return e.EncodeElement((*problem)(p), start)
+}
+
+// ProblemFormatter renders errors as problem details and leaves success
+// bodies to DefaultResponseFormatter:
+//
+//	a.UseFormatter(app.ProblemFormatter{TypeBase: "https://errors.scm.example/"})
+//
+// The title is the status text in the locale of the request, which RFC 9457
+// asks for with type "about:blank" and which does not change between
+// occurrences. An
+// *errpkg.Error, also when wrapped, puts its localized message in detail and its errpkg code in
+// the "code" extension. Validation errors list every invalid field under
+// "errors". Other errors put their message in detail.
+type ProblemFormatter struct {
+	// TypeBase, when set, builds the type URI from the status and errpkg
+	// code, e.g. "https://errors.scm.example/401-101". Otherwise the type is
+	// "about:blank".
+	TypeBase string
+}
+
+func (p ProblemFormatter) Success(c *Context, status int, data any) any {
+	return DefaultResponseFormatter.Success(c, status, data)
+}
+
+func (p ProblemFormatter) Error(c *Context, status int, err error) any {
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  errpkg.StatusText(status, c.locale),
+		Status: status,
+	}
+	if c.request != nil {
+		problem.Instance = c.request.URL.EscapedPath()
+	}
+
+	var er *errpkg.Error
+	var errs errpkg.Errors
+	switch {
+	case errors.As(err, &er):
+		problem.Code = er.Code()
+		problem.Detail = er.LocalizedError(c.locale)
+
+	case errors.As(err, &errs):
+		problem.Code = errpkg.ErrValidationFailed.(*errpkg.Error).Code()
+		problem.Errors = problemDetails(errs, c.locale, "")
+
+		details := make([]string, len(problem.Errors))
+		for i, d := range problem.Errors {
+			details[i] = strings.TrimPrefix(d.Pointer, "/") + ": " + d.Detail
+		}
+		problem.Detail = strings.Join(details, "; ")
+
+	default:
+		problem.Detail = err.Error()
+	}
+
+	if p.TypeBase != "" && problem.Code != 0 {
+		problem.Type = fmt.Sprintf("%s/%d-%d", strings.TrimSuffix(p.TypeBase, "/"), status, problem.Code)
+	}
+	return problem
+}
+
+// problemDetails flattens errs, nested ones included, into details sorted
+// by pointer.
+func problemDetails(errs errpkg.Errors, tag locale.Tag, prefix string) []ProblemDetail {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var details []ProblemDetail
+	for _, key := range keys {
+		pointer := prefix + "/" + pointerEscaper.Replace(key)
+
+		switch er := errs[key].(type) {
+		case errpkg.Errors:
+			details = append(details, problemDetails(er, tag, pointer)...)
+		case *errpkg.Error:
+			details = append(details, ProblemDetail{Pointer: pointer, Detail: er.LocalizedError(tag), Code: er.Code()})
+		default:
+			details = append(details, ProblemDetail{Pointer: pointer, Detail: er.Error()})
+		}
+	}
+	return details
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// problemMediaType returns the problem details variant of mediaType.
+func problemMediaType(mediaType string) string {
+	switch mediaType {
+	case "application/json":
+		return "application/problem+json"
+	case "application/xml":
+		return "application/problem+xml"
+	}
+	return mediaType
+}
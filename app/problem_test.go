@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	errpkg "scm/api/app/errors"
+	"scm/api/app/locale"
+)
+
+func TestProblemFormatter(t *testing.T) {
+	a := newTestApp()
+	a.UseFormatter(ProblemFormatter{TypeBase: "https://errors.example/"})
+
+	r := a.Route()
+	r.GET("/errpkg", func(c *Context) error { return errpkg.ErrRouteNotFound })
+	r.GET("/plain", func(c *Context) error { return errors.New("boom") })
+	r.GET("/wrapped", func(c *Context) error {
+		return fmt.Errorf("load user: %w", errpkg.ErrRouteNotFound)
+	})
+	r.GET("/fields", func(c *Context) error {
+		return errpkg.Errors{"address": errpkg.Errors{"city/town": errpkg.ErrValidationFailed}}
+	})
+
+	tests := []struct {
+		path string
+		want Problem
+	}{
+		{"/errpkg", Problem{
+			Type:     "https://errors.example/404-101",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   errpkg.ErrRouteNotFound.Error(),
+			Instance: "/errpkg",
+			Code:     101,
+		}},
+		{"/wrapped", Problem{
+			Type:     "https://errors.example/404-101",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   errpkg.ErrRouteNotFound.Error(),
+			Instance: "/wrapped",
+			Code:     101,
+		}},
+		{"/plain", Problem{
+			Type:     "about:blank",
+			Title:    "Internal Server Error",
+			Status:   http.StatusInternalServerError,
+			Detail:   "boom",
+			Instance: "/plain",
+		}},
+		{"/fields", Problem{
+			Type:     "https://errors.example/400-114",
+			Title:    "Bad Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "address/city~1town: validation failed",
+			Instance: "/fields",
+			Code:     114,
+			Errors:   []ProblemDetail{{Pointer: "/address/city~1town", Detail: "validation failed", Code: 114}},
+		}},
+	}
+
+	for _, tt := range tests {
+		w := do(a, http.MethodGet, tt.path)
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s: Content-Type %q", tt.path, ct)
+		}
+
+		var got Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tt.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.path, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestProblemFormatterLocalized(t *testing.T) {
+	a := newTestApp()
+	a.UseFormatter(ProblemFormatter{})
+	a.Route().GET("/users/:id", func(c *Context) error {
+		c.UseLocale(locale.Bahasa)
+		return errpkg.ErrRouteNotFound
+	})
+
+	var got Problem
+	w := do(a, http.MethodGet, "/users/1")
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Tidak Ditemukan" || got.Detail != "Sumber daya tidak ditemukan" {
+		t.Errorf("title %q, detail %q, want both in Bahasa", got.Title, got.Detail)
+	}
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	errpkg "scm/api/app/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Renderer encodes response bodies of one media type.
+type Renderer interface {
+	Render(w io.Writer, data any) error
+}
+
+type RendererFunc func(w io.Writer, data any) error
+
+func (f RendererFunc) Render(w io.Writer, data any) error {
+	return f(w, data)
+}
+
+const defaultMediaType = "application/json"
+
+var (
+	JSONRenderer = RendererFunc(func(w io.Writer, data any) error {
+		return json.NewEncoder(w).Encode(data)
+	})
+
+	// XMLRenderer wraps data in a <response> element. Maps become one
+	// element per key and slices one <item> element per value, so the
+	// envelopes built by the Context helpers encode as well.
+	XMLRenderer = RendererFunc(func(w io.Writer, data any) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		return xml.NewEncoder(w).Encode(xmlValue{name: "response", value: data})
+	})
+
+	YAMLRenderer = RendererFunc(func(w io.Writer, data any) error {
+		enc := yaml.NewEncoder(w)
+		if err := enc.Encode(data); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+)
+
+type renderer struct {
+	mediaType string
+	renderer  Renderer
+}
+
+func defaultRenderers() []renderer {
+	return []renderer{
+		{defaultMediaType, JSONRenderer},
+		{"application/xml", XMLRenderer},
+		{"application/yaml", YAMLRenderer},
+	}
+}
+
+// Renderer registers r for mediaType, replacing the one registered before,
+// e.g. a MessagePack encoder for "application/msgpack". JSON, XML and YAML
+// are registered by default. When a client accepts several types equally
+// the one listed first in Accept wins, then the one registered first.
+func (app *App) Renderer(mediaType string, r Renderer) {
+	for i := range app.renderers {
+		if app.renderers[i].mediaType == mediaType {
+			app.renderers[i].renderer = r
+			return
+		}
+	}
+	app.renderers = append(app.renderers, renderer{mediaType, r})
+}
+
+// Negotiate writes data with code in the format the Accept header asks for,
+// using JSON when the header is missing or accepts anything. When no
+// registered format is acceptable it answers 406 with
+// errpkg.ErrNotAcceptable, in JSON, and returns that error.
+func (c *Context) Negotiate(code int, data any) error {
+	renderers := defaultRenderers()
+	if c.app != nil {
+		renderers = c.app.renderers
+	}
+
+	c.writer.Header().Add("Vary", "Accept")
+
+	var err error
+	r, ok := negotiate(renderers, c.request.Header.Get("Accept"))
+	if !ok {
+		er := errpkg.ErrNotAcceptable.(*errpkg.Error)
+		err, code = er, er.HttpStatus()
+		c.httpStatus = code
+		r = renderer{defaultMediaType, JSONRenderer}
+		data = c.formatter().Error(c, code, er)
+	}
+
+	mediaType := r.mediaType
+	if _, ok := data.(*Problem); ok {
+		mediaType = problemMediaType(mediaType)
+	}
+
+	c.writer.Header().Set("Content-Type", mediaType)
+	c.writer.WriteHeader(code)
+	if renderErr := r.renderer.Render(c.writer, data); renderErr != nil {
+		return renderErr
+	}
+	return err
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiate picks the renderer with the highest quality in accept. A
+// structured syntax suffix counts as its base type, so an Accept of
+// "application/vnd.scm.v2+json" is served by the JSON renderer.
+func negotiate(renderers []renderer, accept string) (renderer, bool) {
+	if strings.TrimSpace(accept) == "" {
+		for _, r := range renderers {
+			if r.mediaType == defaultMediaType {
+				return r, true
+			}
+		}
+	}
+
+	ranges := parseAccept(accept)
+
+	var best renderer
+	bestQ, bestIndex := 0.0, len(ranges)
+	for _, r := range renderers {
+		q, index := quality(ranges, r.mediaType)
+		if q > bestQ || (q == bestQ && q > 0 && index < bestIndex) {
+			best, bestQ, bestIndex = r, q, index
+		}
+	}
+	return best, bestQ > 0
+}
+
+// quality returns the q-value the most specific range in ranges gives to
+// mediaType, and the position of that range.
+func quality(ranges []acceptRange, mediaType string) (float64, int) {
+	q, index, specificity := 0.0, len(ranges), 0
+	major, _, _ := strings.Cut(mediaType, "/")
+
+	for i, ar := range ranges {
+		s := 0
+		switch {
+		case ar.mediaType == mediaType || suffixType(ar.mediaType) == mediaType:
+			s = 3
+		case ar.mediaType == major+"/*":
+			s = 2
+		case ar.mediaType == "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, index, specificity = ar.q, i, s
+		}
+	}
+	return q, index
+}
+
+// suffixType maps "application/vnd.scm.v2+json" to "application/json".
+func suffixType(mediaType string) string {
+	major, minor, _ := strings.Cut(mediaType, "/")
+	if i := strings.LastIndexByte(minor, '+'); i >= 0 {
+		return major + "/" + minor[i+1:]
+	}
+	return ""
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+type xmlValue struct {
+	name  string
+	value any
+}
+
+func (x xmlValue) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Local: x.name}}
+
+	v := reflect.ValueOf(x.value)
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch {
+	case !v.IsValid(), (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil():
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := e.Encode(xmlValue{name: fmt.Sprint(key), value: v.MapIndex(key).Interface()}); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := e.Encode(xmlValue{name: "item", value: v.Index(i).Interface()}); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	return e.EncodeElement(x.value, start)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRenderApp() *App {
+	a := newTestApp()
+	a.Route().GET("/user", func(c *Context) error {
+		return c.Success(map[string]any{"name": "alice", "roles": []string{"admin", "ops"}})
+	})
+	return a
+}
+
+func TestNegotiate(t *testing.T) {
+	a := newRenderApp()
+
+	tests := []struct {
+		accept string
+		status int
+		ctype  string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"*/*", http.StatusOK, "application/json"},
+		{"application/xml", http.StatusOK, "application/xml"},
+		{"application/yaml", http.StatusOK, "application/yaml"},
+		{"application/json;q=0.5, application/xml", http.StatusOK, "application/xml"},
+		{"application/yaml;q=0.9, application/xml;q=0.9", http.StatusOK, "application/yaml"},
+		{"text/html, application/*;q=0.2", http.StatusOK, "application/json"},
+		{"application/json;q=0, */*", http.StatusOK, "application/xml"},
+		{"application/vnd.scm.v2+json", http.StatusOK, "application/json"},
+		{"application/vnd.scm.v2+xml", http.StatusOK, "application/xml"},
+		{"text/html", http.StatusNotAcceptable, "application/json"},
+		{"application/json;q=0", http.StatusNotAcceptable, "application/json"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/user", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Code != tt.status || w.Header().Get("Content-Type") != tt.ctype {
+			t.Errorf("Accept %q: %d %s, want %d %s", tt.accept, w.Code, w.Header().Get("Content-Type"), tt.status, tt.ctype)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary %q", tt.accept, w.Header().Get("Vary"))
+		}
+		if tt.status == http.StatusNotAcceptable {
+			var body struct {
+				Code string `json:"code"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Code != "101" {
+				t.Errorf("Accept %q: body %s, want ErrNotAcceptable", tt.accept, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	a := newRenderApp()
+	a.Renderer("text/csv", RendererFunc(func(w io.Writer, data any) error {
+		_, err := fmt.Fprintf(w, "%v", data.(map[string]any)["data"].(map[string]any)["name"])
+		return err
+	}))
+
+	tests := []struct {
+		accept string
+		body   string
+	}{
+		{"application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			"<response><code>200</code><data><name>alice</name><roles><item>admin</item><item>ops</item></roles></data></response>"},
+		{"application/yaml", "code: \"200\"\ndata:\n    name: alice\n    roles:\n        - admin\n        - ops\n"},
+		{"text/csv", "alice"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/user", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Body.String() != tt.body {
+			t.Errorf("%s:\n%s\nwant\n%s", tt.accept, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status, body size and whether the header has
+// been sent, whatever path the response is written through: Context helpers,
+// Context.Writer, wrapped net/http handlers or middleware.
+type responseWriter struct {
+	http.ResponseWriter
+	status    int
+	size      int64
+	committed bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.committed {
+		return
+	}
+
+	// 1xx responses other than 101 may be sent several times before the
+	// final header, e.g. 103 Early Hints.
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+	w.committed = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the sendfile path of the underlying writer available to
+// io.Copy, which http.ServeContent relies on.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("app: response writer does not support hijacking")
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil && !w.committed {
+		w.status = http.StatusSwitchingProtocols
+		w.committed = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writerOnly hides the ReadFrom method of responseWriter from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// Committed reports whether the response header has been sent.
+func (c *Context) Committed() bool {
+	return c.response.committed
+}
+
+// BytesWritten returns the size of the response body written so far.
+func (c *Context) BytesWritten() int64 {
+	return c.response.size
+}
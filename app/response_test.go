@@ -0,0 +1,140 @@
+package app
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// responseState is what a handler observed through the Context after
+// writing.
+type responseState struct {
+	committed bool
+	status    int
+	bytes     int64
+}
+
+func TestResponseTracking(t *testing.T) {
+	logger := &captureLogger{}
+	a := New()
+	a.UseLogger(logger)
+
+	var state responseState
+	observe := func(write func(c *Context)) HandlerFunc {
+		return func(c *Context) error {
+			write(c)
+			state = responseState{c.Committed(), c.HttpStatus(), c.BytesWritten()}
+			return nil
+		}
+	}
+
+	r := a.Route()
+	r.GET("/json", observe(func(c *Context) { c.JSON(http.StatusCreated, map[string]int{"a": 1}) }))
+	r.GET("/header", observe(func(c *Context) { c.Writer().WriteHeader(http.StatusNoContent) }))
+	r.GET("/twice", observe(func(c *Context) {
+		c.Writer().WriteHeader(http.StatusAccepted)
+		c.Writer().WriteHeader(http.StatusInternalServerError)
+	}))
+	r.GET("/early-hints", observe(func(c *Context) {
+		c.Writer().WriteHeader(http.StatusEarlyHints)
+		io.WriteString(c.Writer(), "body")
+	}))
+	r.GET("/write", observe(func(c *Context) { io.WriteString(c.Writer(), "hello") }))
+	r.GET("/readfrom", observe(func(c *Context) { io.Copy(c.Writer(), strings.NewReader("streamed")) }))
+	r.GET("/flush", observe(func(c *Context) { http.NewResponseController(c.Writer()).Flush() }))
+	r.GET("/nothing", observe(func(c *Context) {}))
+
+	tests := []struct {
+		path   string
+		state  responseState
+		logged int
+	}{
+		{"/json", responseState{true, http.StatusCreated, 8}, http.StatusCreated},
+		{"/header", responseState{true, http.StatusNoContent, 0}, http.StatusNoContent},
+		{"/twice", responseState{true, http.StatusAccepted, 0}, http.StatusAccepted},
+		{"/early-hints", responseState{true, http.StatusOK, 4}, http.StatusOK},
+		{"/write", responseState{true, http.StatusOK, 5}, http.StatusOK},
+		{"/readfrom", responseState{true, http.StatusOK, 8}, http.StatusOK},
+		{"/flush", responseState{true, http.StatusOK, 0}, http.StatusOK},
+		{"/nothing", responseState{false, 0, 0}, http.StatusOK},
+	}
+
+	// A real server, so ReadFrom and Flush reach the writers of net/http.
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	for _, tt := range tests {
+		logger.entries = nil
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if state != tt.state {
+			t.Errorf("%s: handler saw %+v, want %+v", tt.path, state, tt.state)
+		}
+		if resp.StatusCode != tt.logged {
+			t.Errorf("%s: client got %d, want %d", tt.path, resp.StatusCode, tt.logged)
+		}
+		if len(logger.entries) != 1 {
+			t.Fatalf("%s: logged %d entries", tt.path, len(logger.entries))
+		}
+		if entry := logger.entries[0]; entry.Status != tt.logged || entry.Bytes != tt.state.bytes {
+			t.Errorf("%s: logged %d with %d bytes, want %d with %d", tt.path, entry.Status, entry.Bytes, tt.logged, tt.state.bytes)
+		}
+	}
+}
+
+func TestResponseHijack(t *testing.T) {
+	logger := &captureLogger{}
+	a := New()
+	a.UseLogger(logger)
+
+	var state responseState
+	a.Route().GET("/raw", func(c *Context) error {
+		conn, rw, err := http.NewResponseController(c.Writer()).Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		state = responseState{c.Committed(), c.HttpStatus(), c.BytesWritten()}
+
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 3\r\nConnection: close\r\n\r\nraw")
+		return rw.Flush()
+	})
+	served := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(served)
+		a.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /raw HTTP/1.1\r\nHost: test\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "raw" {
+		t.Errorf("body %q", body)
+	}
+
+	if want := (responseState{true, http.StatusSwitchingProtocols, 0}); state != want {
+		t.Errorf("handler saw %+v, want %+v", state, want)
+	}
+	// The client is done before the entry is written.
+	<-served
+	if len(logger.entries) != 1 || logger.entries[0].Status != http.StatusSwitchingProtocols {
+		t.Errorf("logged %+v", logger.entries)
+	}
+}
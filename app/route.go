@@ -0,0 +1,342 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Route is returned by the Router registration methods so the endpoint can
+// be named and described after the fact:
+//
+//	r.GET("/users/:id", showUser).Name("users.show").With(app.WithMeta("permission", "users.read"))
+//
+// or, through Router.With, when it is registered.
+type Route struct {
+	Methods      []string
+	Host         string
+	Pattern      string
+	name         string
+	meta         map[string]any
+	app          *App
+	mount        *App
+	handler      HandlerFunc
+	middleware   []MiddlewareFunc
+	errorHandler ErrorHandlerFunc
+	formatter    ResponseFormatter
+	version      string
+	err          error
+
+	// versioned picks the route of the requested version for the routes a
+	// Versions group puts in the tree, or returns nil.
+	versioned func(*Context) *Route
+}
+
+// RouteInfo describes one method/pattern pair for auditing. Middleware lists
+// the full chain in execution order, starting with the App level ones.
+// Version is the API version served, for routes of a Versions group; the
+// unversioned pattern of such a route is listed once per version it serves.
+type RouteInfo struct {
+	Method     string         `json:"method"`
+	Host       string         `json:"host,omitempty"`
+	Pattern    string         `json:"pattern"`
+	Version    string         `json:"version,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	Handler    string         `json:"handler"`
+	Middleware []string       `json:"middleware"`
+	Meta       map[string]any `json:"meta,omitempty"`
+}
+
+// RouteOption configures a Route, see Route.With.
+type RouteOption func(*Route)
+
+// WithMeta attaches value under key, e.g. the permission a route requires
+// or its rate limit class. Middleware reads it through Context.Route.
+func WithMeta(key string, value any) RouteOption {
+	return func(rt *Route) {
+		if rt.meta == nil {
+			rt.meta = make(map[string]any)
+		}
+		rt.meta[key] = value
+	}
+}
+
+// WithName is the option form of Route.Name.
+func WithName(name string) RouteOption {
+	return func(rt *Route) {
+		rt.Name(name)
+	}
+}
+
+// With returns a Router that applies opts to every route registered through
+// it, so metadata is declared next to the route and is in place before the
+// route can match:
+//
+//	r.With(app.WithName("users.show"), app.WithMeta("permission", "users.read")).
+//		GET("/users/:id", showUser, auth)
+//
+// Options of r apply first. Groups created from the returned Router keep
+// opts, which suits WithMeta; WithName must only be used for one route.
+func (r *Router) With(opts ...RouteOption) *Router {
+	with := *r
+	with.options = append(append([]RouteOption{}, r.options...), opts...)
+	return &with
+}
+
+// With applies opts to the route.
+func (rt *Route) With(opts ...RouteOption) *Route {
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// Err returns why the route could not be registered, e.g. because it
+// duplicates an earlier route, or nil. The earlier route keeps serving the
+// paths both match.
+//
+//	if err := r.GET("/users/:id", showUser).Err(); err != nil {
+//		log.Fatal(err)
+//	}
+func (rt *Route) Err() error {
+	return rt.err
+}
+
+// display returns the pattern prefixed with the host, for messages.
+func (rt *Route) display() string {
+	return rt.Host + rt.Pattern
+}
+
+// RouteName returns the name given through Name, or "".
+func (rt *Route) RouteName() string {
+	return rt.name
+}
+
+// Meta returns the metadata stored under key.
+func (rt *Route) Meta(key string) (any, bool) {
+	value, ok := rt.meta[key]
+	return value, ok
+}
+
+// Route returns the route that matched the request, or nil when none did,
+// so middleware can label metrics by pattern or check route metadata.
+func (c *Context) Route() *Route {
+	return c.route
+}
+
+// RoutePattern returns the pattern of the matched route including the
+// prefix of the mounts it was reached through, e.g. for metric labels.
+func (c *Context) RoutePattern() string {
+	if c.route == nil {
+		return ""
+	}
+	return joinPattern(c.prefix, c.route.Pattern)
+}
+
+// RouteMeta returns the metadata of the matched route stored under key as a
+// T. The second result is false when no route matched, the key is not set
+// or it holds another type.
+//
+//	perm, ok := app.RouteMeta[string](c, "permission")
+func RouteMeta[T any](c *Context, key string) (T, bool) {
+	var value T
+	if c.route == nil {
+		return value, false
+	}
+	value, ok := c.route.meta[key].(T)
+	return value, ok
+}
+
+// Name registers the route under name for URL generation. Names are unique
+// per App; reusing one panics at registration time.
+func (rt *Route) Name(name string) *Route {
+	if other, exists := rt.app.names[name]; exists && other != rt {
+		panic(fmt.Sprintf("app: route name %q already used by %s", name, other.Pattern))
+	}
+
+	if rt.name != "" {
+		delete(rt.app.names, rt.name)
+	}
+	rt.name = name
+	rt.app.names[name] = rt
+	return rt
+}
+
+// URL builds the path of the route registered as name. Every required param
+// of the pattern must be present in params and satisfy its constraint;
+// optional params are left out when missing. query, when not empty, is
+// appended as the query string.
+func (app *App) URL(name string, params map[string]string, query url.Values) (string, error) {
+	prefix, route := app.named(name)
+	if route == nil {
+		return "", fmt.Errorf("app: no route named %q", name)
+	}
+
+	path, err := buildPath(joinPattern(prefix, route.Pattern), params)
+	if err != nil {
+		return "", fmt.Errorf("app: route %q: %w", name, err)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+func buildPath(pattern string, params map[string]string) (string, error) {
+	var b strings.Builder
+	for _, seg := range splitSegments(normalizePattern(pattern)) {
+		slash := ""
+		token := seg
+		if strings.HasPrefix(seg, "/") {
+			slash, token = "/", seg[1:]
+		}
+
+		switch {
+		case strings.HasPrefix(token, "*"):
+			name := token[1:]
+			value, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("missing param %q", name)
+			}
+			parts := strings.Split(value, "/")
+			for i := range parts {
+				parts[i] = url.PathEscape(parts[i])
+			}
+			b.WriteString(slash + strings.Join(parts, "/"))
+
+		case strings.HasPrefix(token, ":"):
+			optional := strings.HasSuffix(token, "?")
+			name, constraint := parseParam(strings.TrimSuffix(token[1:], "?"))
+
+			value, ok := params[name]
+			if !ok || value == "" {
+				if optional {
+					continue
+				}
+				return "", fmt.Errorf("missing param %q", name)
+			}
+			if constraint != nil && !constraint.match(value) {
+				return "", fmt.Errorf("param %q value %q does not match <%s>", name, value, constraint.source)
+			}
+			b.WriteString(slash + url.PathEscape(value))
+
+		default:
+			b.WriteString(seg)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "/", nil
+	}
+	return b.String(), nil
+}
+
+// Routes returns every registered route sorted by host, pattern, method and
+// version. Routes of mounted apps are included under their mount prefix, and
+// routes of Versions groups under every path that reaches them: the version
+// prefix, the unversioned pattern and the prefixes of later versions that
+// fall back to them.
+func (app *App) Routes() []RouteInfo {
+	var infos []RouteInfo
+	for _, route := range app.routes {
+		for _, method := range route.Methods {
+			infos = append(infos, app.routeInfo(route, method, route.Pattern, route.version))
+		}
+	}
+	for _, vs := range app.versions {
+		infos = append(infos, vs.routeInfos()...)
+	}
+
+	for _, m := range app.mounts {
+		outer := append(funcNames(app.mw), funcNames(m.middleware)...)
+		for _, info := range m.app.Routes() {
+			info.Pattern = joinPattern(m.pattern, info.Pattern)
+			if info.Host == "" {
+				info.Host = m.host
+			}
+			info.Middleware = append(append([]string{}, outer...), info.Middleware...)
+			infos = append(infos, info)
+		}
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Host != infos[j].Host {
+			return infos[i].Host < infos[j].Host
+		}
+		if infos[i].Pattern != infos[j].Pattern {
+			return infos[i].Pattern < infos[j].Pattern
+		}
+		if infos[i].Method != infos[j].Method {
+			return infos[i].Method < infos[j].Method
+		}
+		return infos[i].Version < infos[j].Version
+	})
+	return infos
+}
+
+// routeInfo describes route as served for method under pattern.
+func (app *App) routeInfo(route *Route, method, pattern, version string) RouteInfo {
+	return RouteInfo{
+		Method:     method,
+		Host:       route.Host,
+		Pattern:    pattern,
+		Version:    version,
+		Name:       route.name,
+		Handler:    funcName(route.handler),
+		Middleware: append(funcNames(app.mw), funcNames(route.middleware)...),
+		Meta:       route.meta,
+	}
+}
+
+// RoutesHandler serves the route table for ops review. It is not registered
+// by default; mount it behind whatever auth the deployment needs:
+//
+//	r.GET("/debug/routes", a.RoutesHandler(), adminOnly)
+//
+// The table is returned as JSON unless the request asks for text through
+// "?format=text" or an Accept header of text/plain.
+func (app *App) RoutesHandler() HandlerFunc {
+	return func(c *Context) error {
+		routes := app.Routes()
+
+		if c.Query("format") != "text" && !strings.Contains(c.request.Header.Get("Accept"), "text/plain") {
+			return c.Success(routes)
+		}
+
+		c.writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.writer.WriteHeader(http.StatusOK)
+
+		tw := tabwriter.NewWriter(c.writer, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "METHOD\tHOST\tPATTERN\tVERSION\tNAME\tHANDLER\tMIDDLEWARE")
+		for _, route := range routes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Host, route.Pattern, route.Version,
+				route.Name, route.Handler, strings.Join(route.Middleware, ", "))
+		}
+		return tw.Flush()
+	}
+}
+
+func funcNames(mws []MiddlewareFunc) []string {
+	names := make([]string, 0, len(mws))
+	for _, mw := range mws {
+		names = append(names, funcName(mw))
+	}
+	return names
+}
+
+func funcName(fn any) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		return f.Name()
+	}
+	return v.Type().String()
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRouterWithOptions(t *testing.T) {
+	a := newTestApp()
+
+	var perm, class string
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			perm, _ = RouteMeta[string](c, "permission")
+			class, _ = RouteMeta[string](c, "rate")
+			return next(c)
+		}
+	})
+
+	r := a.Route()
+	ok := func(c *Context) error { return c.Success(nil) }
+
+	r.With(WithName("users.show"), WithMeta("permission", "users.read")).GET("/users/:id", ok)
+	r.GET("/health", ok)
+	admin := r.With(WithMeta("rate", "strict")).Group("/admin")
+	admin.With(WithMeta("permission", "admin")).POST("/jobs", ok)
+
+	tests := []struct {
+		method, path, perm, class string
+	}{
+		{http.MethodGet, "/users/1", "users.read", ""},
+		{http.MethodGet, "/health", "", ""},
+		{http.MethodPost, "/admin/jobs", "admin", "strict"},
+	}
+	for _, tt := range tests {
+		perm, class = "", ""
+		if w := do(a, tt.method, tt.path); w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d", tt.method, tt.path, w.Code)
+		}
+		if perm != tt.perm || class != tt.class {
+			t.Errorf("%s %s: meta %q %q, want %q %q", tt.method, tt.path, perm, class, tt.perm, tt.class)
+		}
+	}
+
+	if got, err := a.URL("users.show", map[string]string{"id": "7"}, nil); err != nil || got != "/users/7" {
+		t.Errorf("URL = %q, %v", got, err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	a := newTestApp()
+	r := a.Route()
+	ok := func(c *Context) error { return c.Success(nil) }
+
+	r.GET("/users/:id<int>", ok).Name("users.show")
+	r.GET("/posts/:year<int>/:slug?", ok).Name("posts")
+	r.GET("/files/*path", ok).Name("files")
+	r.GET("/search/:q", ok).Name("search")
+	r.Group("/admin").GET("/", ok).Name("admin")
+
+	child := newTestApp()
+	child.Route().GET("/invoices/:id", ok).Name("invoices.show")
+	a.Mount("/billing", child)
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		query  url.Values
+		want   string
+		err    string
+	}{
+		{"users.show", map[string]string{"id": "7"}, nil, "/users/7", ""},
+		{"users.show", map[string]string{"id": "7"}, url.Values{"tab": {"posts"}, "page": {"2"}}, "/users/7?page=2&tab=posts", ""},
+		{"users.show", nil, nil, "", `missing param "id"`},
+		{"users.show", map[string]string{"id": "abc"}, nil, "", `does not match <int>`},
+		{"posts", map[string]string{"year": "2024", "slug": "hello"}, nil, "/posts/2024/hello", ""},
+		{"posts", map[string]string{"year": "2024"}, nil, "/posts/2024", ""},
+		{"files", map[string]string{"path": "docs/a b/c?.txt"}, nil, "/files/docs/a%20b/c%3F.txt", ""},
+		{"files", nil, nil, "", `missing param "path"`},
+		{"search", map[string]string{"q": "a/b"}, nil, "/search/a%2Fb", ""},
+		{"admin", nil, nil, "/admin/", ""},
+		{"invoices.show", map[string]string{"id": "9"}, nil, "/billing/invoices/9", ""},
+		{"missing", nil, nil, "", `no route named "missing"`},
+	}
+
+	for _, tt := range tests {
+		got, err := a.URL(tt.name, tt.params, tt.query)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s %v: error %v, want %q", tt.name, tt.params, err, tt.err)
+		case tt.err == "" && (err != nil || got != tt.want):
+			t.Errorf("%s %v: %q, %v, want %q", tt.name, tt.params, got, err, tt.want)
+		}
+	}
+}
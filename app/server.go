@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"scm/api/app/database"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+type lifecycle struct {
+	mu              sync.Mutex
+	server          *http.Server
+	shutdownTimeout time.Duration
+	onStart         []func() error
+	onShutdown      []func(context.Context) error
+	done            chan struct{}
+	closeOnce       sync.Once
+
+	// stopping is cancelled when Shutdown starts. http.Server.Shutdown
+	// neither cancels running requests nor knows hijacked connections, so
+	// streams and WebSocket connections watch it and are counted in streams.
+	stopping context.Context
+	stop     context.CancelFunc
+	streams  sync.WaitGroup
+}
+
+// track counts a hijacked connection until streams.Done is called, so
+// Shutdown can wait for it. It reports false once Shutdown started.
+func (lc *lifecycle) track() bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.stopping.Err() != nil {
+		return false
+	}
+	lc.streams.Add(1)
+	return true
+}
+
+// streamContext returns a context cancelled when the client disconnects or
+// the app starts shutting down, for responses that run until one of them
+// happens.
+func (c *Context) streamContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Context())
+	if c.app == nil {
+		return ctx, cancel
+	}
+	if c.app.lifecycle.stopping.Err() != nil {
+		// AfterFunc would cancel asynchronously.
+		cancel()
+		return ctx, cancel
+	}
+
+	unregister := context.AfterFunc(c.app.lifecycle.stopping, cancel)
+	return ctx, func() {
+		unregister()
+		cancel()
+	}
+}
+
+// OnStart registers fn to run before the server starts listening. An error
+// aborts the start.
+func (app *App) OnStart(fn func() error) {
+	app.lifecycle.onStart = append(app.lifecycle.onStart, fn)
+}
+
+// OnShutdown registers fn to run once in-flight requests have drained.
+// Hooks run in reverse order of registration, before database connections
+// are closed.
+func (app *App) OnShutdown(fn func(context.Context) error) {
+	app.lifecycle.onShutdown = append(app.lifecycle.onShutdown, fn)
+}
+
+// ShutdownTimeout sets how long a signal triggered shutdown waits for
+// in-flight requests. The default is 30 seconds.
+func (app *App) ShutdownTimeout(d time.Duration) {
+	app.lifecycle.shutdownTimeout = d
+}
+
+// Start serves HTTP on addr until SIGINT or SIGTERM is received or Shutdown
+// is called, and returns once shutdown has finished.
+func (app *App) Start(addr string) error {
+	srv := &http.Server{Addr: addr, Handler: app}
+	return app.StartServer(srv, srv.ListenAndServe)
+}
+
+// StartTLS is Start for HTTPS.
+func (app *App) StartTLS(addr, certFile, keyFile string) error {
+	srv := &http.Server{Addr: addr, Handler: app}
+	return app.StartServer(srv, func() error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// StartServer runs a preconfigured server through the same lifecycle as
+// Start. listen is the blocking call that starts srv, typically
+// srv.ListenAndServe. A nil srv.Handler is set to app. Route conflicts
+// reported by Validate abort the start.
+func (app *App) StartServer(srv *http.Server, listen func() error) error {
+	lc := &app.lifecycle
+
+	if err := app.Validate(); err != nil {
+		return err
+	}
+
+	if srv.Handler == nil {
+		srv.Handler = app
+	}
+	lc.mu.Lock()
+	lc.server = srv
+	lc.mu.Unlock()
+
+	for _, fn := range lc.onStart {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", srv.Addr)
+		errCh <- listen()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		// Shutdown was called elsewhere, wait for it to finish draining.
+		<-lc.done
+		return nil
+
+	case s := <-sig:
+		log.Printf("Received %s, shutting down", s)
+		ctx, cancel := context.WithTimeout(context.Background(), lc.shutdownTimeout)
+		defer cancel()
+		return app.Shutdown(ctx)
+	}
+}
+
+// Shutdown stops accepting connections and ends Stream and SSE responses
+// and WebSocket connections, the latter with CloseGoingAway. It waits for
+// in-flight requests and WebSocket handlers until ctx is done, then runs
+// the OnShutdown hooks and closes every connection opened through
+// database.Connect.
+func (app *App) Shutdown(ctx context.Context) error {
+	lc := &app.lifecycle
+
+	lc.mu.Lock()
+	srv := lc.server
+	lc.stop()
+	lc.mu.Unlock()
+
+	var errs []error
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	streamsDone := make(chan struct{})
+	go func() {
+		lc.streams.Wait()
+		close(streamsDone)
+	}()
+	select {
+	case <-streamsDone:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("app: websocket connections still open: %w", ctx.Err()))
+	}
+
+	for i := len(lc.onShutdown) - 1; i >= 0; i-- {
+		if err := lc.onShutdown[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := database.CloseAll(); err != nil {
+		errs = append(errs, err)
+	}
+
+	lc.closeOnce.Do(func() { close(lc.done) })
+	log.Print("Shutdown completed")
+
+	return errors.Join(errs...)
+}
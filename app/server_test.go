@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startTestServer runs a through StartServer on a random local port and
+// returns its base URL and the result of StartServer.
+func startTestServer(t *testing.T, a *App) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{}
+	result := make(chan error, 1)
+	go func() {
+		result <- a.StartServer(srv, func() error { return srv.Serve(ln) })
+	}()
+	return "http://" + ln.Addr().String(), result
+}
+
+func TestShutdownEndsStreamsBeforeHooks(t *testing.T) {
+	a := newTestApp()
+
+	returned := make(chan struct{})
+	a.Route().GET("/events", func(c *Context) error {
+		defer close(returned)
+		events := c.SSE()
+		if err := events.Send(Event{Data: "ready"}); err != nil {
+			return err
+		}
+		<-events.Done()
+		return events.Send(Event{Data: "too late"})
+	})
+
+	streamEnded := false
+	a.OnShutdown(func(context.Context) error {
+		select {
+		case <-returned:
+			streamEnded = true
+		default:
+		}
+		return nil
+	})
+
+	url, result := startTestServer(t, a)
+
+	resp, err := http.Get(url + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: ready\n" {
+		t.Fatalf("read %q, %v", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %s", elapsed)
+	}
+	if !streamEnded {
+		t.Error("OnShutdown hook ran before the stream ended")
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownRefusesNewStreams(t *testing.T) {
+	a := newTestApp()
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	a.Route().GET("/stream", func(c *Context) error {
+		err = c.Stream("text/plain", func(ctx context.Context, w io.Writer) bool {
+			t.Error("step called after shutdown")
+			return false
+		})
+		return nil
+	})
+	do(a, http.MethodGet, "/stream")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Stream returned %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StaticConfig struct {
+	// Index is served for directory requests.
+	Index string
+	// SPA serves the root Index for missing paths without a file extension,
+	// so client side routes of a single page app survive a reload.
+	SPA bool
+	// Precompressed serves "name.gz" with Content-Encoding: gzip when it
+	// exists and the client accepts gzip.
+	Precompressed bool
+	// MaxAge, when set, is sent as Cache-Control max-age.
+	MaxAge time.Duration
+}
+
+var DefaultStaticConfig = StaticConfig{
+	Index:         "index.html",
+	Precompressed: true,
+}
+
+// Static serves the files below dir under prefix.
+func (r *Router) Static(prefix, dir string) {
+	r.StaticFS(prefix, os.DirFS(dir))
+}
+
+// StaticFS serves fsys, typically an embed.FS, under prefix.
+func (r *Router) StaticFS(prefix string, fsys fs.FS) {
+	r.StaticFSWithConfig(prefix, fsys, DefaultStaticConfig)
+}
+
+// StaticFSWithConfig serves fsys under prefix. Responses carry ETag and
+// Last-Modified validators and honour Range and conditional requests
+// through http.ServeContent. Paths are cleaned before they reach fsys, so
+// ".." can never escape it.
+func (r *Router) StaticFSWithConfig(prefix string, fsys fs.FS, config StaticConfig) {
+	s := &staticFS{app: r.app, fsys: fsys, config: config}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" {
+		r.GET(prefix, s.serve)
+	}
+	r.GET(prefix+"/*filepath", s.serve)
+}
+
+type staticFS struct {
+	app    *App
+	fsys   fs.FS
+	config StaticConfig
+	etags  sync.Map
+}
+
+func (s *staticFS) serve(c *Context) error {
+	name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) || strings.Contains(name, "\\") {
+		return s.app.notFound(c)
+	}
+
+	err := s.serveFile(c, name)
+	if errors.Is(err, fs.ErrNotExist) && s.config.SPA && s.config.Index != "" && path.Ext(name) == "" {
+		err = s.serveFile(c, s.config.Index)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.app.notFound(c)
+	}
+	return err
+}
+
+// serveFile writes name, resolving directories to their index file. It
+// returns an fs.ErrNotExist error without writing anything when there is
+// nothing to serve.
+func (s *staticFS) serveFile(c *Context, name string) error {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if s.config.Index == "" {
+			return fs.ErrNotExist
+		}
+		if p := c.request.URL.Path; !strings.HasSuffix(p, "/") {
+			// Relative links of the index resolve against the directory
+			// only with the trailing slash, like http.FileServer. The
+			// Location stays relative: inside a mounted app the request path
+			// lacks the mount prefix, so http.Redirect would resolve it
+			// wrongly.
+			target := path.Base(p) + "/"
+			if q := c.request.URL.RawQuery; q != "" {
+				target += "?" + q
+			}
+			c.writer.Header().Set("Location", target)
+			c.writer.WriteHeader(http.StatusMovedPermanently)
+			return nil
+		}
+		name = path.Join(name, s.config.Index)
+		if info, err = fs.Stat(s.fsys, name); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fs.ErrNotExist
+		}
+	}
+
+	header := c.writer.Header()
+	served := name
+
+	if s.config.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(c.request) {
+			if gz, err := fs.Stat(s.fsys, name+".gz"); err == nil && !gz.IsDir() {
+				served, info = name+".gz", gz
+				header.Set("Content-Encoding", "gzip")
+			}
+		}
+	}
+
+	f, err := s.fsys.Open(served)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" && served != name {
+		// http.ServeContent would sniff the compressed bytes.
+		if ctype, err = s.sniff(name); err != nil {
+			return err
+		}
+	}
+	if ctype != "" {
+		header.Set("Content-Type", ctype)
+	}
+	if s.config.MaxAge > 0 {
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.config.MaxAge.Seconds())))
+	}
+
+	etag, err := s.etag(served, info, content)
+	if err != nil {
+		return err
+	}
+	header.Set("Etag", etag)
+
+	http.ServeContent(c.writer, c.request, name, info.ModTime(), content)
+	return nil
+}
+
+// sniff detects the content type of name from its first 512 bytes.
+func (s *staticFS) sniff(name string) (string, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// etag derives a validator from size and modification time. Files without
+// a modification time, such as everything in an embed.FS, are hashed once
+// instead and the result is cached.
+func (s *staticFS) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
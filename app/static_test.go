@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFS(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("plain license text"))
+	zw.Close()
+
+	files := fstest.MapFS{
+		"index.html":      {Data: []byte("<p>root</p>")},
+		"docs/index.html": {Data: []byte("<p>docs</p>")},
+		"LICENSE":         {Data: []byte("plain license text")},
+		"LICENSE.gz":      {Data: gz.Bytes()},
+	}
+
+	a := newTestApp()
+	a.Route().StaticFS("/static", files)
+	a.Route().StaticFSWithConfig("/spa", files, StaticConfig{Index: "index.html", SPA: true})
+
+	child := newTestApp()
+	child.Route().StaticFS("/static", files)
+	a.Mount("/billing", child)
+
+	tests := []struct {
+		path    string
+		request map[string]string
+		status  int
+		header  string
+		want    string
+	}{
+		{"/static/", nil, http.StatusOK, "Content-Type", "text/html; charset=utf-8"},
+		{"/static/docs/", nil, http.StatusOK, "Content-Type", "text/html; charset=utf-8"},
+		{"/static/docs", nil, http.StatusMovedPermanently, "Location", "docs/"},
+		{"/static/docs?v=2", nil, http.StatusMovedPermanently, "Location", "docs/?v=2"},
+		{"/static", nil, http.StatusMovedPermanently, "Location", "static/"},
+		{"/billing/static/docs", nil, http.StatusMovedPermanently, "Location", "docs/"},
+		{"/static/LICENSE", nil, http.StatusOK, "Content-Type", "text/plain; charset=utf-8"},
+		{"/static/LICENSE", map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "Content-Type", "text/plain; charset=utf-8"},
+		{"/static/LICENSE", map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "Content-Encoding", "gzip"},
+		{"/static/LICENSE", map[string]string{"Accept-Encoding": "gzip;q=0"}, http.StatusOK, "Content-Encoding", ""},
+		{"/static/LICENSE", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "Content-Range", "bytes 0-4/18"},
+		{"/static/LICENSE", map[string]string{"Range": "bytes=50-"}, http.StatusRequestedRangeNotSatisfiable, "", ""},
+		{"/static/missing.txt", nil, http.StatusNotFound, "", ""},
+		{"/static/missing", nil, http.StatusNotFound, "", ""},
+		{"/spa/users/42", nil, http.StatusOK, "Content-Type", "text/html; charset=utf-8"},
+		{"/spa/missing.js", nil, http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		for k, v := range tt.request {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %v: status %d, want %d", tt.path, tt.request, w.Code, tt.status)
+			continue
+		}
+		if tt.header != "" && w.Header().Get(tt.header) != tt.want {
+			t.Errorf("%s %v: %s = %q, want %q", tt.path, tt.request, tt.header, w.Header().Get(tt.header), tt.want)
+		}
+	}
+
+	if w := do(a, http.MethodGet, "/spa/users/42"); w.Body.String() != "<p>root</p>" {
+		t.Errorf("SPA fallback served %q", w.Body.String())
+	}
+}
+
+func TestStaticFSConditional(t *testing.T) {
+	a := newTestApp()
+	a.Route().StaticFS("/static", fstest.MapFS{
+		"app.js": {Data: []byte("console.log(1)")},
+	})
+
+	w := do(a, http.MethodGet, "/static/app.js")
+	etag := w.Header().Get("Etag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, Etag %q", w.Code, etag)
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{etag, http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+		{"*", http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("If-None-Match %s: status %d, want %d", tt.ifNoneMatch, w.Code, tt.status)
+		}
+	}
+}
+
+func TestStaticTraversal(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "public"), 0o755)
+	os.WriteFile(filepath.Join(dir, "public", "a.txt"), []byte("public"), 0o644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644)
+
+	// Without CleanPath the ".." reaches the static handler itself.
+	a := NewWithConfig(RouterConfig{})
+	a.UseLogger(nil)
+	a.Route().Static("/static", filepath.Join(dir, "public"))
+
+	for _, path := range []string{
+		"/static/../secret.txt",
+		"/static/%2e%2e/secret.txt",
+		"/static/a/../../secret.txt",
+		"/static/..\\secret.txt",
+	} {
+		w := do(a, http.MethodGet, path)
+		if bytes.Contains(w.Body.Bytes(), []byte("secret")) {
+			t.Errorf("%s served the file outside the directory", path)
+		}
+	}
+
+	if w := do(a, http.MethodGet, "/static/a/../a.txt"); w.Body.String() != "public" {
+		t.Errorf("cleaned path inside the directory served %q", w.Body.String())
+	}
+}
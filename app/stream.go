@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stream writes a response of contentType piece by piece. step is called
+// until it returns false, the client disconnects or the app shuts down, and
+// what it wrote is flushed after every call. ctx is cancelled in the latter
+// two cases, so a step that waits must also wait on ctx.Done.
+// context.Canceled is returned when the stream was cut short.
+//
+//	return c.Stream("text/plain", func(ctx context.Context, w io.Writer) bool {
+//		select {
+//		case line, ok := <-lines:
+//			if ok {
+//				fmt.Fprintln(w, line)
+//			}
+//			return ok
+//		case <-ctx.Done():
+//			return false
+//		}
+//	})
+func (c *Context) Stream(contentType string, step func(ctx context.Context, w io.Writer) bool) error {
+	ctx, cancel := c.streamContext()
+	defer cancel()
+	done := ctx.Done()
+
+	c.writer.Header().Set("Content-Type", contentType)
+	c.writer.WriteHeader(http.StatusOK)
+
+	for {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+
+		more := step(ctx, c.writer)
+		if err := c.flush(); err != nil {
+			return err
+		}
+		if !more {
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Context) flush() error {
+	return http.NewResponseController(c.writer).Flush()
+}
+
+// Event is one Server-Sent Event. Data is written as is when it is a string
+// or []byte and JSON encoded otherwise; multi-line data is split over
+// several "data:" lines at "\r\n", "\r" and "\n".
+type Event struct {
+	ID    string
+	Event string
+	Data  any
+	// Retry, when set, tells the client how long to wait before
+	// reconnecting.
+	Retry time.Duration
+}
+
+const defaultHeartbeat = 15 * time.Second
+
+// EventStream writes Server-Sent Events. It is safe for concurrent use, so
+// events can be produced from several goroutines while the handler waits.
+type EventStream struct {
+	c         *Context
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	ticker    *time.Ticker
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// SSE starts a text/event-stream response. A comment line is sent every 15
+// seconds while no event is, so proxies keep the connection open; see
+// EventStream.Heartbeat. The stream is closed when the handler returns, and
+// ends early when the client disconnects or the app shuts down.
+//
+//	events := c.SSE()
+//	for p := range progress(events.LastEventID()) {
+//		if err := events.Send(app.Event{ID: p.ID, Event: "progress", Data: p}); err != nil {
+//			return err
+//		}
+//	}
+//	return nil
+func (c *Context) SSE() *EventStream {
+	if c.sse != nil {
+		return c.sse
+	}
+
+	header := c.writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	c.writer.WriteHeader(http.StatusOK)
+	c.flush()
+
+	s := &EventStream{
+		c:      c,
+		ticker: time.NewTicker(defaultHeartbeat),
+		stop:   make(chan struct{}),
+	}
+	s.ctx, s.cancel = c.streamContext()
+	c.sse = s
+
+	s.stopped.Add(1)
+	go s.heartbeat()
+	return s
+}
+
+// LastEventID returns the Last-Event-ID header a reconnecting client sends,
+// so the stream can resume after the last event it received.
+func (s *EventStream) LastEventID() string {
+	return s.c.request.Header.Get("Last-Event-ID")
+}
+
+// Done is closed when the client disconnects or the app shuts down.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes and flushes event. It fails once Done is closed.
+func (s *EventStream) Send(event Event) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	if event.Data != nil {
+		var data string
+		switch v := event.Data.(type) {
+		case string:
+			data = v
+		case []byte:
+			data = string(v)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			data = string(encoded)
+		}
+
+		for _, line := range strings.Split(lineBreaks.Replace(data), "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Heartbeat changes the interval of the keep-alive comments. Zero turns
+// them off.
+func (s *EventStream) Heartbeat(d time.Duration) {
+	if d <= 0 {
+		s.ticker.Stop()
+		return
+	}
+	s.ticker.Reset(d)
+}
+
+// Close stops the heartbeat. Nothing is written once Close returns.
+func (s *EventStream) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.ticker.Stop()
+		s.cancel()
+	})
+	s.stopped.Wait()
+
+	// Wait for a Send of another goroutine that is still writing.
+	s.mu.Lock()
+	s.mu.Unlock()
+}
+
+func (s *EventStream) write(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-s.stop:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	if _, err := io.WriteString(s.c.writer, p); err != nil {
+		return err
+	}
+	return s.c.flush()
+}
+
+func (s *EventStream) heartbeat() {
+	defer s.stopped.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-s.ticker.C:
+			if s.write(":\n\n") != nil {
+				return
+			}
+		}
+	}
+}
+
+// lineBreaks turns every line terminator of the event stream format into
+// "\n". A lone "\r" ends a line too, so it must not reach the client inside
+// a data line.
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	a := newTestApp()
+	a.Route().GET("/events", func(c *Context) error {
+		events := c.SSE()
+		events.Send(Event{ID: "1", Event: "greeting", Data: "hello\r\nworld"})
+		events.Send(Event{Data: "hello\rid: injected\revent: evil"})
+		events.Send(Event{ID: "2\nid: 3", Data: map[string]int{"n": 2}, Retry: 3 * time.Second})
+		return nil
+	})
+
+	w := do(a, http.MethodGet, "/events")
+
+	want := "id: 1\nevent: greeting\ndata: hello\ndata: world\n\n" +
+		"data: hello\ndata: id: injected\ndata: event: evil\n\n" +
+		"id: 2id: 3\nretry: 3000\ndata: {\"n\":2}\n\n"
+	if w.Body.String() != want {
+		t.Errorf("body\n%q\nwant\n%q", w.Body.String(), want)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q", ct)
+	}
+}
+
+func TestStream(t *testing.T) {
+	a := newTestApp()
+	a.Route().GET("/count", func(c *Context) error {
+		i := 0
+		return c.Stream("text/plain", func(ctx context.Context, w io.Writer) bool {
+			i++
+			fmt.Fprintln(w, i)
+			return i < 3
+		})
+	})
+
+	w := do(a, http.MethodGet, "/count")
+	if w.Body.String() != "1\n2\n3\n" || !w.Flushed {
+		t.Errorf("body %q, flushed %v", w.Body.String(), w.Flushed)
+	}
+}
+
+func TestStreamEndsWaitingStepOnDisconnect(t *testing.T) {
+	a := newTestApp()
+	lines := make(chan string)
+	result := make(chan error, 1)
+	a.Route().GET("/tail", func(c *Context) error {
+		err := c.Stream("text/plain", func(ctx context.Context, w io.Writer) bool {
+			select {
+			case line := <-lines:
+				fmt.Fprintln(w, line)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		result <- err
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/tail", nil).WithContext(ctx)
+	go a.ServeHTTP(httptest.NewRecorder(), r)
+
+	lines <- "first"
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Stream returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("step still waiting after the client disconnected")
+	}
+}
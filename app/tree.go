@@ -0,0 +1,449 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type nodeKind uint8
+
+const (
+	staticKind nodeKind = iota
+	paramKind
+	catchAllKind
+)
+
+// node is a single vertex of the compressed radix tree used by Router.
+// Static children are keyed by the first byte of their prefix, so at most
+// one static child can match a given path; param children are only tried
+// when the static branch fails, which makes "/users/me" always win over
+// "/users/:id", and the catch-all child is tried last.
+type node struct {
+	kind       nodeKind
+	label      byte
+	prefix     string
+	constraint *paramConstraint
+	static     []*node
+	params     []*node
+	catchAll   *node
+	endpoints  map[string]*routeEntry
+}
+
+type tree struct {
+	root      *node
+	maxParams int
+	foldCase  bool
+}
+
+func newTree(foldCase bool) *tree {
+	return &tree{root: &node{kind: staticKind}, foldCase: foldCase}
+}
+
+// paramStep records where add descended into a param vertex, so the
+// siblings of that vertex can be checked for ambiguous routes.
+type paramStep struct {
+	parent *node
+	node   *node
+	rest   string
+}
+
+// add registers entry under method and pattern. Param names are stored on
+// the entry instead of the node so that "/a/:id" and "/a/:name/b" can share
+// the same param vertex, and every method names them on its own. An entry
+// that would replace an existing one, or that matches the same paths as an
+// entry for method behind an overlapping constraint, e.g. "/a/:x<int>" and
+// "/a/:y<uint>", is rejected with an error and the existing entry is kept.
+func (t *tree) add(method, pattern string, entry *routeEntry) error {
+	n := t.root
+	var names []string
+	var steps []paramStep
+
+	for len(pattern) > 0 {
+		i := strings.IndexAny(pattern, ":*")
+		if i < 0 {
+			n = n.insertStatic(t.fold(pattern))
+			break
+		}
+		if i > 0 {
+			n = n.insertStatic(t.fold(pattern[:i]))
+		}
+
+		end := i + segmentEnd(pattern[i:])
+		token := pattern[i+1 : end]
+
+		if pattern[i] == '*' {
+			if end != len(pattern) {
+				panic(fmt.Sprintf("app: catch-all *%s must be the last segment", token))
+			}
+			names = append(names, token)
+			n = n.insertCatchAll()
+			break
+		}
+
+		name, constraint := parseParam(token)
+		names = append(names, name)
+		parent := n
+		n = n.insertParam(constraint)
+		pattern = pattern[end:]
+		steps = append(steps, paramStep{parent: parent, node: n, rest: pattern})
+	}
+
+	if len(names) > t.maxParams {
+		t.maxParams = len(names)
+	}
+
+	entry.paramNames = names
+	if existing := n.endpoints[method]; existing != nil {
+		return fmt.Errorf("app: route %s %s conflicts with %s", method, entry.route.display(), existing.route.display())
+	}
+	for _, step := range steps {
+		for _, sibling := range step.parent.params {
+			if sibling == step.node || !overlapping(sibling.constraint, step.node.constraint) {
+				continue
+			}
+			if other := t.locate(sibling, step.rest); other != nil && other.endpoints[method] != nil {
+				return fmt.Errorf("app: route %s %s is ambiguous with %s, a segment can satisfy both constraints",
+					method, entry.route.display(), other.endpoints[method].route.display())
+			}
+		}
+	}
+
+	if n.endpoints == nil {
+		n.endpoints = make(map[string]*routeEntry)
+	}
+	n.endpoints[method] = entry
+	return nil
+}
+
+func (t *tree) addRoute(route *Route) error {
+	var errs []error
+	for _, method := range route.Methods {
+		for _, pattern := range expandPattern(route.Pattern) {
+			if err := t.add(method, pattern, &routeEntry{route: route}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// locate returns the vertex pattern leads to below n, or nil when it was
+// never added.
+func (t *tree) locate(n *node, pattern string) *node {
+	for len(pattern) > 0 && n != nil {
+		i := strings.IndexAny(pattern, ":*")
+		if i < 0 {
+			return n.locateStatic(t.fold(pattern))
+		}
+		if i > 0 {
+			if n = n.locateStatic(t.fold(pattern[:i])); n == nil {
+				return nil
+			}
+		}
+
+		end := i + segmentEnd(pattern[i:])
+		if pattern[i] == '*' {
+			return n.catchAll
+		}
+
+		_, constraint := parseParam(pattern[i+1 : end])
+		n = n.paramChild(constraint)
+		pattern = pattern[end:]
+	}
+	return n
+}
+
+// lookup finds the entry registered for method that matches path. Param
+// values are appended to ps in declaration order and named from the matched
+// entry; params already in ps, such as host params, are kept.
+func (t *tree) lookup(method, path string, ps *Params) *routeEntry {
+	base := len(*ps)
+	n, values := t.root.find(method, path, t.fold(path), *ps)
+	if n == nil {
+		*ps = values[:base]
+		return nil
+	}
+
+	entry := n.endpoint(method)
+	for i := base; i < len(values); i++ {
+		values[i].Key = entry.paramNames[i-base]
+	}
+	*ps = values
+	return entry
+}
+
+// allowed lists every method that has a route matching path, including the
+// HEAD and OPTIONS responses the router provides on its own.
+func (t *tree) allowed(path string) []string {
+	methods := t.root.allowed(path, t.fold(path), nil)
+	if len(methods) == 0 {
+		return nil
+	}
+
+	if hasMethod(methods, http.MethodGet) {
+		methods = appendMethod(methods, http.MethodHead)
+	}
+	methods = appendMethod(methods, http.MethodOptions)
+	sort.Strings(methods)
+
+	return methods
+}
+
+// fold lowers ASCII letters when the tree is case-insensitive. Only ASCII is
+// folded so the result keeps the byte offsets of s, which lets find cut
+// param values out of the original path.
+func (t *tree) fold(s string) string {
+	if !t.foldCase {
+		return s
+	}
+
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if 'A' <= b[j] && b[j] <= 'Z' {
+					b[j] += 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+// endpoint returns the entry for method, serving HEAD from GET when no
+// explicit HEAD route was registered.
+func (n *node) endpoint(method string) *routeEntry {
+	if entry := n.endpoints[method]; entry != nil {
+		return entry
+	}
+	if method == http.MethodHead {
+		return n.endpoints[http.MethodGet]
+	}
+	return nil
+}
+
+func (n *node) staticChild(label byte) *node {
+	for _, child := range n.static {
+		if child.label == label {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *node) insertStatic(path string) *node {
+	for len(path) > 0 {
+		child := n.staticChild(path[0])
+		if child == nil {
+			child = &node{kind: staticKind, label: path[0], prefix: path}
+			n.static = append(n.static, child)
+			return child
+		}
+
+		l := commonPrefix(child.prefix, path)
+		if l < len(child.prefix) {
+			child.split(l)
+		}
+
+		path = path[l:]
+		n = child
+	}
+	return n
+}
+
+func (n *node) locateStatic(path string) *node {
+	for len(path) > 0 {
+		child := n.staticChild(path[0])
+		if child == nil || !strings.HasPrefix(path, child.prefix) {
+			return nil
+		}
+		path = path[len(child.prefix):]
+		n = child
+	}
+	return n
+}
+
+// split cuts n.prefix at l and moves everything below the cut into a new
+// child, keeping n itself in place so parents don't need to be updated.
+func (n *node) split(l int) {
+	rest := &node{
+		kind:      staticKind,
+		label:     n.prefix[l],
+		prefix:    n.prefix[l:],
+		static:    n.static,
+		params:    n.params,
+		catchAll:  n.catchAll,
+		endpoints: n.endpoints,
+	}
+
+	n.prefix = n.prefix[:l]
+	n.static = []*node{rest}
+	n.params = nil
+	n.catchAll = nil
+	n.endpoints = nil
+}
+
+// insertParam returns the param child for constraint, creating it when
+// needed. Constrained params are kept ahead of the unconstrained one so
+// ":id<int>" gets the first chance at a segment.
+func (n *node) insertParam(constraint *paramConstraint) *node {
+	if child := n.paramChild(constraint); child != nil {
+		return child
+	}
+
+	child := &node{kind: paramKind, constraint: constraint}
+	if constraint == nil {
+		n.params = append(n.params, child)
+		return child
+	}
+
+	i := len(n.params)
+	if i > 0 && n.params[i-1].constraint == nil {
+		i--
+	}
+	n.params = append(n.params[:i], append([]*node{child}, n.params[i:]...)...)
+	return child
+}
+
+func (n *node) paramChild(constraint *paramConstraint) *node {
+	source := ""
+	if constraint != nil {
+		source = constraint.source
+	}
+
+	for _, child := range n.params {
+		if child.constraintSource() == source {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *node) insertCatchAll() *node {
+	if n.catchAll == nil {
+		n.catchAll = &node{kind: catchAllKind}
+	}
+	return n.catchAll
+}
+
+func (n *node) constraintSource() string {
+	if n.constraint == nil {
+		return ""
+	}
+	return n.constraint.source
+}
+
+// accepts reports whether a param node takes value as its segment.
+func (n *node) accepts(value string) bool {
+	return value != "" && (n.constraint == nil || n.constraint.match(value))
+}
+
+// find walks the subtree below n for path, which is what is left after n
+// itself matched. Static prefixes are compared against key, the folded form
+// of path, while param values are cut from path itself. Static children are
+// preferred over params, and a failed branch backtracks to the next
+// candidate so the result never depends on registration order.
+func (n *node) find(method, path, key string, ps Params) (*node, Params) {
+	if path == "" {
+		if n.endpoint(method) != nil {
+			return n, ps
+		}
+	} else {
+		if child := n.staticChild(key[0]); child != nil && strings.HasPrefix(key, child.prefix) {
+			l := len(child.prefix)
+			if found, values := child.find(method, path[l:], key[l:], ps); found != nil {
+				return found, values
+			}
+		}
+
+		if len(n.params) > 0 {
+			l := paramEnd(path)
+			value := path[:l]
+			for _, param := range n.params {
+				if !param.accepts(value) {
+					continue
+				}
+				values := append(ps, Param{Value: value})
+				if found, values := param.find(method, path[l:], key[l:], values); found != nil {
+					return found, values
+				}
+			}
+		}
+	}
+
+	if n.catchAll != nil && n.catchAll.endpoint(method) != nil {
+		return n.catchAll, append(ps, Param{Value: path})
+	}
+
+	return nil, ps
+}
+
+// allowed is like find but explores every branch that matches path and
+// collects the methods registered on each of them.
+func (n *node) allowed(path, key string, methods []string) []string {
+	if path == "" {
+		for method := range n.endpoints {
+			methods = appendMethod(methods, method)
+		}
+	} else {
+		if child := n.staticChild(key[0]); child != nil && strings.HasPrefix(key, child.prefix) {
+			l := len(child.prefix)
+			methods = child.allowed(path[l:], key[l:], methods)
+		}
+
+		l := paramEnd(path)
+		for _, param := range n.params {
+			if param.accepts(path[:l]) {
+				methods = param.allowed(path[l:], key[l:], methods)
+			}
+		}
+	}
+
+	if n.catchAll != nil {
+		for method := range n.catchAll.endpoints {
+			methods = appendMethod(methods, method)
+		}
+	}
+
+	return methods
+}
+
+func paramEnd(path string) int {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return i
+	}
+	return len(path)
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func appendMethod(methods []string, method string) []string {
+	if hasMethod(methods, method) {
+		return methods
+	}
+	return append(methods, method)
+}
+
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
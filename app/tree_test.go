@@ -0,0 +1,283 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestTree(t testing.TB, patterns ...string) *tree {
+	t.Helper()
+	tr := newTree(false)
+	for _, pattern := range patterns {
+		if err := tr.addRoute(&Route{Methods: []string{http.MethodGet}, Pattern: pattern}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tr
+}
+
+// match returns the pattern of the route tr serves path with, or "".
+func match(tr *tree, path string) (string, Params) {
+	var ps Params
+	entry := tr.lookup(http.MethodGet, path, &ps)
+	if entry == nil {
+		return "", nil
+	}
+	return entry.route.Pattern, ps
+}
+
+func TestTreeLookup(t *testing.T) {
+	patterns := []string{
+		"/",
+		"/users",
+		"/users/me",
+		"/users/:id",
+		"/users/:id/posts",
+		"/users/:id/posts/:post",
+		"/a/b/c",
+		"/a/:x/d",
+		"/files/readme",
+		"/files/:name",
+		"/files/*path",
+		"/orders/:id<int>",
+		"/orders/:ref",
+	}
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  string
+	}{
+		{"/", "/", ""},
+		{"/users", "/users", ""},
+		{"/users/me", "/users/me", ""},
+		{"/users/42", "/users/:id", "id=42"},
+		{"/users/mee", "/users/:id", "id=mee"},
+		{"/users/m", "/users/:id", "id=m"},
+		{"/users/me/posts", "/users/:id/posts", "id=me"},
+		{"/users/42/posts/7", "/users/:id/posts/:post", "id=42 post=7"},
+		{"/users/", "", ""},
+		{"/a/b/c", "/a/b/c", ""},
+		{"/a/b/d", "/a/:x/d", "x=b"},
+		{"/files/readme", "/files/readme", ""},
+		{"/files/license", "/files/:name", "name=license"},
+		{"/files/docs/readme", "/files/*path", "path=docs/readme"},
+		{"/files/", "/files/*path", "path="},
+		{"/orders/12", "/orders/:id<int>", "id=12"},
+		{"/orders/x12", "/orders/:ref", "ref=x12"},
+		{"/missing", "", ""},
+	}
+
+	// The result must not depend on the order routes were registered in.
+	orders := map[string][]string{"forward": patterns, "reverse": reversed(patterns)}
+	for name, order := range orders {
+		tr := newTestTree(t, order...)
+		for _, tt := range tests {
+			pattern, ps := match(tr, tt.path)
+			if pattern != tt.pattern || formatParams(ps) != tt.params {
+				t.Errorf("%s: %s matched %q with %q, want %q with %q",
+					name, tt.path, pattern, formatParams(ps), tt.pattern, tt.params)
+			}
+		}
+	}
+}
+
+func TestTreeHeadFallsBackToGet(t *testing.T) {
+	tr := newTestTree(t, "/users/:id")
+
+	var ps Params
+	if entry := tr.lookup(http.MethodHead, "/users/1", &ps); entry == nil {
+		t.Fatal("HEAD /users/1 not served by the GET route")
+	}
+	if entry := tr.lookup(http.MethodPost, "/users/1", &ps); entry != nil {
+		t.Fatal("POST /users/1 matched a GET route")
+	}
+
+	want := []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	if got := tr.allowed("/users/1"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("allowed = %v, want %v", got, want)
+	}
+}
+
+func TestTreeCaseInsensitive(t *testing.T) {
+	tr := newTree(true)
+	if err := tr.addRoute(&Route{Methods: []string{http.MethodGet}, Pattern: "/Users/:id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pattern, ps := match(tr, "/USERS/AbC")
+	if pattern != "/Users/:id" || ps.ByName("id") != "AbC" {
+		t.Fatalf("matched %q with %v", pattern, ps)
+	}
+}
+
+func TestTreeLookupDoesNotAllocate(t *testing.T) {
+	tr := newTestTree(t, "/users/:id/posts/:post", "/files/*path")
+	ps := make(Params, 0, tr.maxParams)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ps = ps[:0]
+		tr.lookup(http.MethodGet, "/users/42/posts/7", &ps)
+	})
+	if allocs != 0 {
+		t.Fatalf("lookup allocated %v times", allocs)
+	}
+}
+
+func TestTreeConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		routes   [][2]string
+		conflict bool
+	}{
+		{"duplicate", [][2]string{{"GET", "/users/:id"}, {"GET", "/users/:id"}}, true},
+		{"renamed param", [][2]string{{"GET", "/users/:id"}, {"GET", "/users/:userID"}}, true},
+		{"param names per method", [][2]string{{"GET", "/users/:id"}, {"PUT", "/users/:userID"}}, false},
+		{"overlapping constraints", [][2]string{{"GET", "/a/:x<int>"}, {"GET", "/a/:y<uint>"}}, true},
+		{"overlapping constraints deeper", [][2]string{{"GET", "/a/:x<alpha>/b"}, {"GET", "/a/:y<alnum>/b"}}, true},
+		{"overlapping constraints other method", [][2]string{{"GET", "/a/:x<int>"}, {"POST", "/a/:y<uint>"}}, false},
+		{"overlapping constraints other suffix", [][2]string{{"GET", "/a/:x<int>/b"}, {"GET", "/a/:y<uint>/c"}}, false},
+		{"disjoint constraints", [][2]string{{"GET", "/a/:x<int>"}, {"GET", "/a/:y<uuid>"}, {"GET", "/a/:z<alpha>"}}, false},
+		{"constraint and plain param", [][2]string{{"GET", "/a/:x<int>"}, {"GET", "/a/:y"}}, false},
+		{"optional param", [][2]string{{"GET", "/a"}, {"GET", "/a/:page?"}}, true},
+	}
+
+	for _, tt := range tests {
+		tr := newTree(false)
+		var err error
+		for _, route := range tt.routes {
+			if e := tr.addRoute(&Route{Methods: []string{route[0]}, Pattern: route[1]}); e != nil {
+				err = e
+			}
+		}
+		if (err != nil) != tt.conflict {
+			t.Errorf("%s: error %v, want conflict %v", tt.name, err, tt.conflict)
+		}
+	}
+
+	tr := newTree(false)
+	tr.addRoute(&Route{Methods: []string{http.MethodGet}, Pattern: "/users/:id"})
+	tr.addRoute(&Route{Methods: []string{http.MethodPut}, Pattern: "/users/:userID"})
+	var ps Params
+	tr.lookup(http.MethodPut, "/users/7", &ps)
+	if ps.ByName("userID") != "7" {
+		t.Errorf("PUT params %v", ps)
+	}
+}
+
+func TestRouteErr(t *testing.T) {
+	a := newTestApp()
+	r := a.Route()
+	ok := func(c *Context) error { return c.Success(nil) }
+
+	if err := r.GET("/users/:id", ok).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Group("/users").GET("/:name", ok).Err(); err == nil {
+		t.Fatal("duplicate route registered without error")
+	}
+	if err := a.Validate(); err == nil {
+		t.Fatal("Validate missed the duplicate")
+	}
+}
+
+func reversed(s []string) []string {
+	r := make([]string, len(s))
+	for i, v := range s {
+		r[len(s)-1-i] = v
+	}
+	return r
+}
+
+func formatParams(ps Params) string {
+	parts := make([]string, len(ps))
+	for i, p := range ps {
+		parts[i] = p.Key + "=" + p.Value
+	}
+	return strings.Join(parts, " ")
+}
+
+// benchRoutes is a REST API of typical size, with the static and param
+// siblings that made the map based router pick a random winner.
+var benchRoutes = func() []string {
+	routes := []string{"/", "/health", "/users/me", "/search"}
+	for _, resource := range []string{"users", "orders", "products", "invoices", "customers", "shipments"} {
+		routes = append(routes,
+			"/"+resource,
+			"/"+resource+"/:id",
+			"/"+resource+"/:id/history",
+			"/"+resource+"/:id/items/:item",
+		)
+	}
+	return routes
+}()
+
+var benchPaths = []string{
+	"/health",
+	"/users/me",
+	"/orders/12345",
+	"/shipments/987/history",
+	"/customers/42/items/7",
+}
+
+// legacyRouter is the map based matching the radix tree replaced, kept here
+// to compare against.
+type legacyRouter map[string]map[string]struct{}
+
+func (r legacyRouter) lookup(method, path string) (string, map[string]string) {
+	for pattern := range r[method] {
+		if params, ok := legacyMatchRoute(pattern, path); ok {
+			return pattern, params
+		}
+	}
+	return "", nil
+}
+
+func legacyMatchRoute(pattern, path string) (map[string]string, bool) {
+	parts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	if len(parts) != len(pathParts) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i := range parts {
+		if strings.HasPrefix(parts[i], ":") {
+			params[parts[i][1:]] = pathParts[i]
+		} else if parts[i] != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func BenchmarkLookup(b *testing.B) {
+	tr := newTestTree(b, benchRoutes...)
+	legacy := legacyRouter{http.MethodGet: {}}
+	for _, pattern := range benchRoutes {
+		legacy[http.MethodGet][pattern] = struct{}{}
+	}
+
+	for _, path := range benchPaths {
+		b.Run(fmt.Sprintf("radix%s", path), func(b *testing.B) {
+			ps := make(Params, 0, tr.maxParams)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ps = ps[:0]
+				if tr.lookup(http.MethodGet, path, &ps) == nil {
+					b.Fatal("no match")
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("legacy%s", path), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if pattern, _ := legacy.lookup(http.MethodGet, path); pattern == "" {
+					b.Fatal("no match")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package app
+
+// Set stores value under key for the rest of the request, typically from a
+// middleware for the handler, e.g. the authenticated user or the tenant.
+func (c *Context) Set(key string, value any) {
+	if c.values == nil {
+		c.values = make(map[string]any)
+	}
+	c.values[key] = value
+}
+
+// Get returns the value stored under key.
+func (c *Context) Get(key string) (any, bool) {
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// Value returns the value stored under key as a T. The second result is
+// false when key is not set or holds another type.
+//
+//	user, ok := app.Value[*models.User](c, "user")
+func Value[T any](c *Context, key string) (T, bool) {
+	value, ok := c.values[key].(T)
+	return value, ok
+}
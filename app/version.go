@@ -0,0 +1,281 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type VersionConfig struct {
+	// Default is used when the request names no version at all.
+	Default string
+	// Header carries the requested version, e.g. "Accept-Version: v2".
+	Header string
+	// Vendor enables media type versioning, so with "scm" an Accept of
+	// "application/vnd.scm.v2+json" selects v2.
+	Vendor string
+}
+
+var DefaultVersionConfig = VersionConfig{
+	Header: "Accept-Version",
+}
+
+// Versions groups the versions of one API. Every version shares the same
+// route table: a route is reachable as "/v2/users" and as "/users" with the
+// version taken from the header or media type, and a version that does not
+// define a route falls back to the latest earlier version that does.
+//
+//	api := r.Versions(app.VersionConfig{Default: "v1", Header: "Accept-Version", Vendor: "scm"})
+//	v1, v2 := api.Version("v1"), api.Version("v2")
+//	api.Deprecate("v1", since, sunset)
+type Versions struct {
+	router      *Router
+	config      VersionConfig
+	names       []string
+	deprecated  map[string]deprecation
+	dispatchers []*versionDispatcher
+}
+
+type deprecation struct {
+	since  time.Time
+	sunset time.Time
+}
+
+type apiVersion struct {
+	versions *Versions
+	name     string
+}
+
+// versionDispatcher owns one method and unversioned pattern and picks the
+// route to run for the requested version.
+type versionDispatcher struct {
+	versions *Versions
+	method   string
+	pattern  string
+	routes   map[string]*Route
+}
+
+func (r *Router) Versions(config VersionConfig) *Versions {
+	vs := &Versions{
+		router:     r,
+		config:     config,
+		deprecated: make(map[string]deprecation),
+	}
+	r.app.versions = append(r.app.versions, vs)
+	return vs
+}
+
+// Version returns the Router for routes of version name. Versions are
+// ordered by declaration, which is the order used for fallback.
+func (vs *Versions) Version(name string) *Router {
+	if !vs.known(name) {
+		vs.names = append(vs.names, name)
+		for _, d := range vs.dispatchers {
+			d.register(name)
+		}
+	}
+
+	return &Router{
+		app:          vs.router.app,
+		host:         vs.router.host,
+		prefix:       vs.router.prefix + "/" + name,
+		routes:       vs.router.routes,
+		middleware:   append([]MiddlewareFunc{}, vs.router.middleware...),
+		version:      &apiVersion{versions: vs, name: name},
+		errorHandler: vs.router.errorHandler,
+		formatter:    vs.router.formatter,
+		options:      vs.router.options,
+	}
+}
+
+// Deprecate marks version so that its responses carry a Deprecation header
+// (RFC 9745) and, when sunset is not zero, a Sunset header (RFC 8594). A
+// zero since sends "Deprecation: true".
+func (vs *Versions) Deprecate(version string, since, sunset time.Time) {
+	vs.deprecated[version] = deprecation{since: since, sunset: sunset}
+}
+
+func (vs *Versions) known(name string) bool {
+	for _, n := range vs.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// add attaches route to the dispatchers of its unversioned pattern.
+func (v *apiVersion) add(route *Route) error {
+	vs := v.versions
+	pattern := vs.router.prefix + strings.TrimPrefix(route.Pattern, vs.router.prefix+"/"+v.name)
+
+	var errs []error
+	for _, method := range route.Methods {
+		d := vs.dispatcher(method, pattern)
+		if existing, ok := d.routes[v.name]; ok {
+			errs = append(errs, fmt.Errorf("app: route %s %s conflicts with %s", method, route.display(), existing.display()))
+			continue
+		}
+		d.routes[v.name] = route
+	}
+	return errors.Join(errs...)
+}
+
+func (vs *Versions) dispatcher(method, pattern string) *versionDispatcher {
+	for _, d := range vs.dispatchers {
+		if d.method == method && d.pattern == pattern {
+			return d
+		}
+	}
+
+	d := &versionDispatcher{
+		versions: vs,
+		method:   method,
+		pattern:  pattern,
+		routes:   make(map[string]*Route),
+	}
+	vs.dispatchers = append(vs.dispatchers, d)
+
+	d.register("")
+	for _, name := range vs.names {
+		d.register(name)
+	}
+	return d
+}
+
+// register adds d to the tree, under the version prefix when version is
+// set and under the bare pattern otherwise.
+func (d *versionDispatcher) register(version string) {
+	vs := d.versions
+	err := vs.router.routes.addRoute(&Route{
+		Methods:   []string{d.method},
+		Host:      vs.router.host,
+		Pattern:   d.patternFor(version),
+		app:       vs.router.app,
+		versioned: d.route(version),
+	})
+	if err != nil {
+		vs.router.app.conflict(err)
+	}
+}
+
+// route returns the versioned func of the tree route registered for fixed,
+// or for the unversioned pattern when fixed is "". It sets the version of c
+// and the deprecation headers of the resolved route.
+func (d *versionDispatcher) route(fixed string) func(*Context) *Route {
+	return func(c *Context) *Route {
+		vs := d.versions
+
+		version := fixed
+		if version == "" {
+			version = vs.requested(c.request)
+		}
+
+		route := d.resolve(version)
+		if route == nil {
+			return nil
+		}
+
+		c.version = version
+		if dep, ok := vs.deprecated[version]; ok {
+			header := c.writer.Header()
+			if dep.since.IsZero() {
+				header.Set("Deprecation", "true")
+			} else {
+				header.Set("Deprecation", "@"+strconv.FormatInt(dep.since.Unix(), 10))
+			}
+			if !dep.sunset.IsZero() {
+				header.Set("Sunset", dep.sunset.UTC().Format(http.TimeFormat))
+			}
+		}
+		return route
+	}
+}
+
+// patternFor returns the pattern d is registered under for version, or the
+// unversioned one for "".
+func (d *versionDispatcher) patternFor(version string) string {
+	if version == "" {
+		return d.pattern
+	}
+	vs := d.versions
+	return vs.router.prefix + "/" + version + strings.TrimPrefix(d.pattern, vs.router.prefix)
+}
+
+// routeInfos lists the paths the dispatchers serve besides the versioned
+// routes themselves: the unversioned pattern for every version that has the
+// route, and the prefix of versions that fall back to an earlier one.
+func (vs *Versions) routeInfos() []RouteInfo {
+	app := vs.router.app
+
+	var infos []RouteInfo
+	for _, d := range vs.dispatchers {
+		for _, name := range vs.names {
+			route := d.resolve(name)
+			if route == nil {
+				continue
+			}
+			infos = append(infos, app.routeInfo(route, d.method, d.pattern, name))
+			if _, own := d.routes[name]; !own {
+				infos = append(infos, app.routeInfo(route, d.method, d.patternFor(name), name))
+			}
+		}
+	}
+	return infos
+}
+
+// resolve returns the route of version, or of the latest earlier version
+// that defines it.
+func (d *versionDispatcher) resolve(version string) *Route {
+	names := d.versions.names
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] != version {
+			continue
+		}
+		for ; i >= 0; i-- {
+			if route, ok := d.routes[names[i]]; ok {
+				return route
+			}
+		}
+	}
+	return nil
+}
+
+// requested reads the version from the configured header, then from a
+// vendor media type in Accept, and falls back to the default version.
+func (vs *Versions) requested(r *http.Request) string {
+	if vs.config.Header != "" {
+		if v := strings.TrimSpace(r.Header.Get(vs.config.Header)); v != "" {
+			return vs.canonical(v)
+		}
+	}
+
+	if vs.config.Vendor != "" {
+		prefix := "application/vnd." + vs.config.Vendor + "."
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+			if err != nil || !strings.HasPrefix(mediaType, prefix) {
+				continue
+			}
+			v := strings.TrimPrefix(mediaType, prefix)
+			if i := strings.IndexByte(v, '+'); i >= 0 {
+				v = v[:i]
+			}
+			return vs.canonical(v)
+		}
+	}
+
+	return vs.config.Default
+}
+
+// canonical maps "2" to "v2" when only the latter is declared.
+func (vs *Versions) canonical(v string) string {
+	if !vs.known(v) && vs.known("v"+v) {
+		return "v" + v
+	}
+	return v
+}
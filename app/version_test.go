@@ -0,0 +1,139 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionedRouteMetaInGlobalMiddleware(t *testing.T) {
+	a := newTestApp()
+	a.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if c.Route() == nil {
+				return c.Unauthorized(errors.New("no permission"))
+			}
+			if permission, _ := c.Route().Meta("permission"); permission != "users.read" {
+				return c.Unauthorized(errors.New("no permission"))
+			}
+			return next(c)
+		}
+	})
+
+	vs := a.Route().Versions(VersionConfig{Default: "v1", Header: "Accept-Version"})
+	vs.Version("v1").GET("/users", func(c *Context) error {
+		return c.Success(c.Version())
+	}).With(WithMeta("permission", "users.read"))
+
+	for _, path := range []string{"/v1/users", "/users"} {
+		if w := do(a, http.MethodGet, path); w.Code != http.StatusOK {
+			t.Errorf("%s: status %d, the global middleware missed the route meta", path, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("Accept-Version", "v9")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown version: status %d, want the middleware to see no route", w.Code)
+	}
+}
+
+func usersV1(c *Context) error  { return c.Success("usersV1 " + c.Version()) }
+func usersV2(c *Context) error  { return c.Success("usersV2 " + c.Version()) }
+func ordersV1(c *Context) error { return c.Success("ordersV1 " + c.Version()) }
+
+func newVersionedApp() *App {
+	a := newTestApp()
+	vs := a.Route().Group("/api").Versions(VersionConfig{Default: "v1", Header: "Accept-Version", Vendor: "scm"})
+	v1, v2 := vs.Version("v1"), vs.Version("v2")
+	vs.Version("v3")
+
+	v1.GET("/users", usersV1)
+	v1.GET("/orders", ordersV1)
+	v2.GET("/users", usersV2)
+
+	vs.Deprecate("v1", time.Unix(1700000000, 0), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	vs.Deprecate("v3", time.Time{}, time.Time{})
+	return a
+}
+
+func TestVersionDispatch(t *testing.T) {
+	a := newVersionedApp()
+
+	tests := []struct {
+		path        string
+		header      string
+		value       string
+		status      int
+		body        string
+		deprecation string
+		sunset      string
+	}{
+		{"/api/v1/users", "", "", http.StatusOK, "usersV1 v1", "@1700000000", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"/api/v2/users", "", "", http.StatusOK, "usersV2 v2", "", ""},
+		{"/api/users", "", "", http.StatusOK, "usersV1 v1", "@1700000000", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"/api/users", "Accept-Version", "v2", http.StatusOK, "usersV2 v2", "", ""},
+		{"/api/users", "Accept-Version", "2", http.StatusOK, "usersV2 v2", "", ""},
+		{"/api/users", "Accept", "application/vnd.scm.v2+json", http.StatusOK, "usersV2 v2", "", ""},
+		{"/api/users", "Accept", "text/html, application/vnd.scm.v1+json;q=0.9", http.StatusOK, "usersV1 v1", "@1700000000", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"/api/v2/orders", "", "", http.StatusOK, "ordersV1 v2", "", ""},
+		{"/api/v3/users", "", "", http.StatusOK, "usersV2 v3", "true", ""},
+		{"/api/users", "Accept-Version", "v9", http.StatusNotFound, "", "", ""},
+		{"/api/v9/users", "", "", http.StatusNotFound, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		name := tt.path + " " + tt.header + " " + tt.value
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", name, w.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && !strings.Contains(w.Body.String(), `"`+tt.body+`"`) {
+			t.Errorf("%s: body %s, want %q", name, w.Body.String(), tt.body)
+		}
+		if got := w.Header().Get("Deprecation"); got != tt.deprecation {
+			t.Errorf("%s: Deprecation %q, want %q", name, got, tt.deprecation)
+		}
+		if got := w.Header().Get("Sunset"); got != tt.sunset {
+			t.Errorf("%s: Sunset %q, want %q", name, got, tt.sunset)
+		}
+	}
+}
+
+func TestVersionRoutes(t *testing.T) {
+	var got []string
+	for _, info := range newVersionedApp().Routes() {
+		handler := info.Handler[strings.LastIndexByte(info.Handler, '.')+1:]
+		got = append(got, strings.Join([]string{info.Method, info.Pattern, info.Version, handler}, " "))
+	}
+
+	want := []string{
+		"GET /api/orders v1 ordersV1",
+		"GET /api/orders v2 ordersV1",
+		"GET /api/orders v3 ordersV1",
+		"GET /api/users v1 usersV1",
+		"GET /api/users v2 usersV2",
+		"GET /api/users v3 usersV2",
+		"GET /api/v1/orders v1 ordersV1",
+		"GET /api/v1/users v1 usersV1",
+		"GET /api/v2/orders v2 ordersV1",
+		"GET /api/v2/users v2 usersV2",
+		"GET /api/v3/orders v3 ordersV1",
+		"GET /api/v3/users v3 usersV2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Routes:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,633 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	errpkg "scm/api/app/errors"
+)
+
+// Message types of WSConn.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Close codes of RFC 6455 section 7.4.1.
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatus            = 1005
+	CloseAbnormalClosure     = 1006
+	CloseInvalidPayload      = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseInternalServerError = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	wsGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultReadLimit = 1 << 20
+	wsWriteWait      = 10 * time.Second
+	wsCloseWait      = time.Second
+	maxCloseText     = 123
+)
+
+type WSConfig struct {
+	// ReadLimit is the largest message accepted, in bytes. Larger messages
+	// close the connection with CloseMessageTooBig. Zero means the 1 MiB of
+	// DefaultWSConfig.
+	ReadLimit int64
+	// PingInterval, when set, sends a ping at that interval.
+	PingInterval time.Duration
+	// PongWait, when set, closes connections that send nothing, not even a
+	// pong, for that long.
+	PongWait time.Duration
+	// Subprotocols lists the supported subprotocols in order of preference.
+	// The first one the client offers is negotiated.
+	Subprotocols []string
+	// CheckOrigin accepts or rejects the Origin of the handshake. The
+	// default accepts requests without Origin and same host ones.
+	CheckOrigin func(r *http.Request) bool
+}
+
+var DefaultWSConfig = WSConfig{
+	ReadLimit:    defaultReadLimit,
+	PingInterval: 30 * time.Second,
+	PongWait:     60 * time.Second,
+}
+
+// CloseError is returned by WSConn reads once the connection is closing.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+var errWSClosed = errors.New("websocket: connection closed")
+
+// WS registers a WebSocket endpoint on path with DefaultWSConfig. The
+// handshake is an ordinary GET route, so the middleware of the group and
+// mws run before the upgrade, e.g. for auth or locale; c.Context() of the
+// connection gives access to what they stored.
+//
+//	r.WS("/dashboard", func(conn *app.WSConn) error {
+//		for {
+//			var msg Subscribe
+//			if err := conn.ReadJSON(&msg); err != nil {
+//				return err
+//			}
+//			...
+//		}
+//	}, auth)
+//
+// When h returns the connection is closed with CloseNormalClosure, or with
+// CloseInternalServerError when h failed.
+func (r *Router) WS(path string, h func(*WSConn) error, mws ...MiddlewareFunc) *Route {
+	return r.WSWithConfig(path, DefaultWSConfig, h, mws...)
+}
+
+// WSWithConfig is WS with explicit limits and keepalive settings.
+func (r *Router) WSWithConfig(path string, config WSConfig, h func(*WSConn) error, mws ...MiddlewareFunc) *Route {
+	return r.GET(path, func(c *Context) error {
+		conn, err := upgrade(c, config)
+		if err != nil {
+			return err
+		}
+		// Releases the connection when h panics; a no-op otherwise.
+		defer conn.Close(CloseInternalServerError, "")
+
+		err = h(conn)
+
+		var closeErr *CloseError
+		switch {
+		case errors.As(err, &closeErr):
+			conn.Close(closeErr.Code, "")
+			if closeErr.Code == CloseNormalClosure || closeErr.Code == CloseGoingAway || closeErr.Code == CloseNoStatus {
+				err = nil
+			}
+		case err != nil:
+			conn.Close(CloseInternalServerError, "")
+		default:
+			conn.Close(CloseNormalClosure, "")
+		}
+		return err
+	}, mws...)
+}
+
+func upgrade(c *Context, config WSConfig) (*WSConn, error) {
+	r := c.request
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, errpkg.ErrInvalidWebSocketHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.writer.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, errpkg.ErrInvalidWebSocketHandshake
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, errpkg.ErrInvalidWebSocketHandshake
+	}
+
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, errpkg.ErrOriginNotAllowed
+	}
+
+	var offered []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(value, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+	protocol := ""
+	for _, supported := range config.Subprotocols {
+		if contains(offered, supported) {
+			protocol = supported
+			break
+		}
+	}
+
+	// http.Server.Shutdown does not wait for hijacked connections, so the
+	// app counts them itself.
+	release := func() {}
+	if c.app != nil {
+		if !c.app.lifecycle.track() {
+			return nil, errpkg.ErrServiceUnavailable
+		}
+		release = c.app.lifecycle.streams.Done
+	}
+
+	netConn, brw, err := http.NewResponseController(c.writer).Hijack()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + wsGUID))
+	header := c.writer.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(accept[:]))
+	if protocol != "" {
+		header.Set("Sec-WebSocket-Protocol", protocol)
+	}
+
+	netConn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		release()
+		return nil, err
+	}
+	netConn.SetWriteDeadline(time.Time{})
+
+	conn := &WSConn{
+		c:        c,
+		conn:     netConn,
+		br:       brw.Reader,
+		protocol: protocol,
+		pongWait: config.PongWait,
+		release:  release,
+		done:     make(chan struct{}),
+	}
+	conn.SetReadLimit(config.ReadLimit)
+	conn.ctx, conn.cancel = c.streamContext()
+	conn.extendReadDeadline()
+	go conn.keepalive(config.PingInterval)
+	return conn, nil
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WSConn is a server side WebSocket connection. One goroutine may read
+// while others write; pings are answered while reading. Close may be called
+// from any goroutine.
+type WSConn struct {
+	c         *Context
+	conn      net.Conn
+	br        *bufio.Reader
+	protocol  string
+	readLimit int64
+	pongWait  time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	release func()
+
+	wmu       sync.Mutex
+	closeSent bool
+
+	// rmu serializes reads between ReadMessage and Close.
+	rmu           sync.Mutex
+	closeReceived bool
+	closeOnce     sync.Once
+	done          chan struct{}
+}
+
+// Context returns the Context of the handshake request, with the params,
+// locale and values set by the route middleware. It is valid until the
+// handler returns.
+func (c *WSConn) Context() *Context {
+	return c.c
+}
+
+// Subprotocol returns the negotiated subprotocol, or "".
+func (c *WSConn) Subprotocol() string {
+	return c.protocol
+}
+
+// SetReadLimit changes the largest message accepted, in bytes. Zero or less
+// restores the 1 MiB default.
+func (c *WSConn) SetReadLimit(n int64) {
+	if n <= 0 {
+		n = defaultReadLimit
+	}
+	c.readLimit = n
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped on the way. Once the client closes the connection, or
+// breaks the protocol, a *CloseError is returned.
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	var messageType int
+	var message []byte
+
+	for {
+		fin, op, payload, err := c.readFrame(int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+		c.extendReadDeadline()
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.receiveClose(payload)
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unfinished message")
+			}
+			messageType = int(op)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		message = append(message, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends data as one TextMessage or BinaryMessage.
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// ReadJSON reads the next message and decodes it into v.
+func (c *WSConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSON sends v encoded as a text message.
+func (c *WSConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping with data, at most 125 bytes.
+func (c *WSConn) Ping(data []byte) error {
+	return c.writeFrame(opPing, data)
+}
+
+// Close sends a close frame with code and reason, waits briefly for the
+// client to answer and closes the connection. A ReadMessage running in
+// another goroutine returns within that wait and may consume the answer.
+// Calls after the first one do nothing.
+func (c *WSConn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.writeClose(code, reason)
+		if err == nil {
+			c.conn.SetReadDeadline(time.Now().Add(wsCloseWait))
+			c.rmu.Lock()
+			for !c.closeReceived {
+				_, op, _, readErr := c.readFrame(0)
+				if readErr != nil || op == opClose {
+					break
+				}
+			}
+			c.rmu.Unlock()
+		}
+
+		close(c.done)
+		c.cancel()
+		if closeErr := c.conn.Close(); err == nil {
+			err = closeErr
+		}
+		c.release()
+	})
+	return err
+}
+
+func (c *WSConn) readFrame(buffered int64) (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, c.broken(err)
+	}
+
+	fin := head[0]&0x80 != 0
+	op := head[0] & 0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "unmasked client frame")
+	}
+
+	n := int64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.broken(err)
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.broken(err)
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid length")
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if op >= opClose && (n > 125 || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if op < opClose && n > c.readLimit-buffered {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, c.broken(err)
+	}
+
+	// The buffer grows with the bytes that actually arrive rather than with
+	// the length the client claims.
+	payload, err := io.ReadAll(io.LimitReader(c.br, n))
+	if err == nil && int64(len(payload)) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return false, 0, nil, c.broken(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, op, payload, nil
+}
+
+func (c *WSConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return errWSClosed
+	}
+	if op >= opClose && len(payload) > 125 {
+		return errors.New("websocket: control frame payload too long")
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|op)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	_, err := c.conn.Write(frame)
+	if op == opClose {
+		c.closeSent = true
+	}
+	return err
+}
+
+// writeClose sends a close frame. Codes that must not be sent, such as
+// CloseNoStatus and CloseAbnormalClosure, send one without a code. The
+// reason is cut to fit the frame without splitting a character.
+func (c *WSConn) writeClose(code int, reason string) error {
+	var payload []byte
+	if validCloseCode(code) {
+		if len(reason) > maxCloseText {
+			n := maxCloseText
+			for n > 0 && !utf8.RuneStart(reason[n]) {
+				n--
+			}
+			reason = reason[:n]
+		}
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, errWSClosed) {
+		return nil
+	}
+	return err
+}
+
+// receiveClose answers the close frame of the client with the same code.
+func (c *WSConn) receiveClose(payload []byte) error {
+	c.closeReceived = true
+
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, "invalid utf-8")
+		}
+	}
+
+	c.writeClose(closeErr.Code, "")
+	return closeErr
+}
+
+// validCloseCode reports whether code may be sent in a close frame: the
+// codes RFC 6455 and the IANA registry define for that, and the 3000-4999
+// range left to libraries and applications. 1005, 1006 and 1015 only
+// describe a closure locally.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail closes the connection after a protocol violation of the client.
+func (c *WSConn) fail(code int, reason string) error {
+	c.writeClose(code, reason)
+	c.closeReceived = true
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// broken reports a connection that went away without a close frame.
+func (c *WSConn) broken(err error) error {
+	var netErr net.Error
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		c.closeReceived = true
+		return &CloseError{Code: CloseAbnormalClosure, Reason: err.Error()}
+	}
+	return err
+}
+
+func (c *WSConn) extendReadDeadline() {
+	c.wmu.Lock()
+	closing := c.closeSent
+	c.wmu.Unlock()
+
+	// Once a close frame went out only the short wait for the answer holds.
+	if c.pongWait > 0 && !closing {
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	}
+}
+
+// keepalive pings the client every interval, when set, and closes the
+// connection with CloseGoingAway when the app shuts down.
+func (c *WSConn) keepalive(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.ctx.Done():
+			// The handler sees the CloseError once the client answers, or
+			// the read deadline passes.
+			c.writeClose(CloseGoingAway, "server shutting down")
+			c.conn.SetReadDeadline(time.Now().Add(wsCloseWait))
+			return
+		case <-tick:
+			if c.Ping(nil) != nil {
+				return
+			}
+		}
+	}
+}
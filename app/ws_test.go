@@ -0,0 +1,459 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// wsClient is a minimal RFC 6455 client speaking over a raw connection, so
+// the server side is tested against the wire format itself.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+const wsTestKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// dialWS performs the handshake for path with extra header lines, which may
+// override the version. It returns the client after a 101 and the response
+// otherwise.
+func dialWS(t *testing.T, srv *httptest.Server, path, extra string) (*wsClient, *http.Response) {
+	t.Helper()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if !strings.Contains(extra, "Sec-WebSocket-Version:") {
+		extra += "Sec-WebSocket-Version: 13\r\n"
+	}
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + wsTestKey + "\r\n" + extra + "\r\n"))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp
+	}
+	// The accept value of the handshake example in RFC 6455 section 1.3.
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept %q", accept)
+	}
+	return &wsClient{t: t, conn: conn, br: br}, resp
+}
+
+// send writes a masked frame.
+func (w *wsClient) send(op byte, fin bool, payload []byte) {
+	head := op
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	w.conn.Write(frame)
+}
+
+func (w *wsClient) sendClose(code int) {
+	w.send(opClose, true, binary.BigEndian.AppendUint16(nil, uint16(code)))
+}
+
+// recv reads the next frame, which the server must not mask.
+func (w *wsClient) recv() (byte, []byte) {
+	w.t.Helper()
+	w.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var head [2]byte
+	if _, err := readFull(w.br, head[:]); err != nil {
+		w.t.Fatal(err)
+	}
+	if head[0]&0x80 == 0 || head[1]&0x80 != 0 {
+		w.t.Fatalf("unexpected frame header %x", head)
+	}
+
+	n := int(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		readFull(w.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		readFull(w.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	payload := make([]byte, n)
+	if _, err := readFull(w.br, payload); err != nil {
+		w.t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+// recvClose skips pings and returns the code of the close frame.
+func (w *wsClient) recvClose() (int, string) {
+	w.t.Helper()
+	for {
+		op, payload := w.recv()
+		switch {
+		case op == opPing:
+			continue
+		case op != opClose:
+			w.t.Fatalf("got opcode %d, want close", op)
+		case len(payload) < 2:
+			return CloseNoStatus, ""
+		}
+		return int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+	}
+}
+
+func readFull(br *bufio.Reader, p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		m, err := br.Read(p[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// newWSTestApp serves echo handlers behind a middleware that rejects
+// requests with X-Deny and stores the user for the handler. Handler results
+// are sent to the returned channel.
+func newWSTestApp(t *testing.T) (*App, <-chan error) {
+	a := newTestApp()
+	results := make(chan error, 10)
+
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if c.Request().Header.Get("X-Deny") != "" {
+				return c.Unauthorized(errors.New("denied"))
+			}
+			c.Writer().Header().Set("X-User", "alice")
+			c.Set("user", "alice")
+			return next(c)
+		}
+	}
+
+	echo := func(conn *WSConn) error {
+		if user, _ := Value[string](conn.Context(), "user"); user != "alice" {
+			t.Errorf("middleware value %q", user)
+		}
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				results <- err
+				return err
+			}
+
+			switch string(data) {
+			case "json":
+				var v struct{ N int }
+				err = conn.WriteJSON(map[string]int{"n": 1})
+				if err == nil {
+					err = conn.ReadJSON(&v)
+				}
+				if err == nil {
+					err = conn.WriteJSON(v)
+				}
+			case "fail":
+				results <- nil
+				return errors.New("handler failed")
+			case "bye":
+				results <- nil
+				return conn.Close(4000, strings.Repeat("é", 100))
+			default:
+				err = conn.WriteMessage(messageType, data)
+			}
+			if err != nil {
+				results <- err
+				return err
+			}
+		}
+	}
+
+	r := a.Route()
+	r.WS("/echo", echo, auth)
+
+	config := DefaultWSConfig
+	config.ReadLimit = 10
+	config.PingInterval = 20 * time.Millisecond
+	config.Subprotocols = []string{"chat"}
+	r.WSWithConfig("/small", config, echo, auth)
+
+	config = DefaultWSConfig
+	config.ReadLimit = 0
+	r.WSWithConfig("/unlimited", config, echo, auth)
+
+	return a, results
+}
+
+func TestWSHandshake(t *testing.T) {
+	a, _ := newWSTestApp(t)
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	c, resp := dialWS(t, srv, "/small", "Sec-WebSocket-Protocol: foo, chat\r\n")
+	if c == nil {
+		t.Fatalf("handshake failed with %s", resp.Status)
+	}
+	if resp.Header.Get("X-User") != "alice" {
+		t.Error("headers of the route middleware not sent with the 101")
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "chat" {
+		t.Errorf("subprotocol %q", p)
+	}
+
+	tests := []struct {
+		name   string
+		extra  string
+		status int
+	}{
+		{"middleware", "X-Deny: 1\r\n", http.StatusUnauthorized},
+		{"origin", "Origin: http://evil.example\r\n", http.StatusForbidden},
+		{"version", "Sec-WebSocket-Version: 8\r\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if c, resp := dialWS(t, srv, "/echo", tt.extra); c != nil || resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	// Without upgrade headers the route answers like any other.
+	if w := do(a, http.MethodGet, "/echo"); w.Code != http.StatusBadRequest {
+		t.Errorf("plain GET: status %d", w.Code)
+	}
+}
+
+func TestWSMessages(t *testing.T) {
+	a, results := newWSTestApp(t)
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	c, _ := dialWS(t, srv, "/echo", "")
+
+	c.send(opText, true, []byte("hello"))
+	if op, p := c.recv(); op != opText || string(p) != "hello" {
+		t.Fatalf("echo %d %q", op, p)
+	}
+
+	// A fragmented binary message with a ping in between.
+	c.send(opBinary, false, []byte{1, 2})
+	c.send(opPing, true, []byte("ping"))
+	c.send(opContinuation, true, []byte{3})
+	if op, p := c.recv(); op != opPong || string(p) != "ping" {
+		t.Fatalf("pong %d %q", op, p)
+	}
+	if op, p := c.recv(); op != opBinary || string(p) != "\x01\x02\x03" {
+		t.Fatalf("echo %d %v", op, p)
+	}
+
+	large := strings.Repeat("x", 70000)
+	c.send(opText, true, []byte(large))
+	if op, p := c.recv(); op != opText || string(p) != large {
+		t.Fatalf("large echo %d, %d bytes", op, len(p))
+	}
+
+	c.send(opText, true, []byte("json"))
+	if _, p := c.recv(); string(p) != `{"n":1}` {
+		t.Fatalf("WriteJSON %s", p)
+	}
+	c.send(opText, true, []byte(`{"N":5}`))
+	if _, p := c.recv(); string(p) != `{"N":5}` {
+		t.Fatalf("ReadJSON round trip %s", p)
+	}
+
+	c.sendClose(CloseNormalClosure)
+	if code, _ := c.recvClose(); code != CloseNormalClosure {
+		t.Fatalf("close code %d", code)
+	}
+	var closeErr *CloseError
+	if err := <-results; !errors.As(err, &closeErr) || closeErr.Code != CloseNormalClosure {
+		t.Fatalf("handler saw %v", err)
+	}
+}
+
+func TestWSCloseCodes(t *testing.T) {
+	a, results := newWSTestApp(t)
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		path string
+		send func(c *wsClient)
+		code int
+	}{
+		{"handler error", "/echo", func(c *wsClient) {
+			c.send(opText, true, []byte("fail"))
+		}, CloseInternalServerError},
+		{"invalid utf-8", "/echo", func(c *wsClient) {
+			c.send(opText, true, []byte{0xff})
+		}, CloseInvalidPayload},
+		{"unmasked frame", "/echo", func(c *wsClient) {
+			c.conn.Write([]byte{0x81, 0x01, 'x'})
+		}, CloseProtocolError},
+		{"reserved close code", "/echo", func(c *wsClient) {
+			c.sendClose(CloseNoStatus)
+		}, CloseProtocolError},
+		{"read limit", "/small", func(c *wsClient) {
+			c.send(opText, true, []byte("01234567890"))
+		}, CloseMessageTooBig},
+		{"read limit across fragments", "/small", func(c *wsClient) {
+			c.send(opText, false, []byte("012345"))
+			c.send(opContinuation, true, []byte("67890"))
+		}, CloseMessageTooBig},
+		{"claimed length", "/unlimited", func(c *wsClient) {
+			frame := []byte{0x82, 0x80 | 127}
+			frame = binary.BigEndian.AppendUint64(frame, 1<<62)
+			c.conn.Write(append(frame, 1, 2, 3, 4))
+		}, CloseMessageTooBig},
+	}
+
+	for _, tt := range tests {
+		c, _ := dialWS(t, srv, tt.path, "")
+		tt.send(c)
+		if code, _ := c.recvClose(); code != tt.code {
+			t.Errorf("%s: close code %d, want %d", tt.name, code, tt.code)
+		}
+		<-results
+		c.sendClose(tt.code)
+	}
+
+	c, _ := dialWS(t, srv, "/echo", "")
+	c.send(opText, true, []byte("bye"))
+	code, reason := c.recvClose()
+	if code != 4000 || !utf8.ValidString(reason) || len(reason) > 123 {
+		t.Errorf("close %d with %d byte reason, valid utf-8 %v", code, len(reason), utf8.ValidString(reason))
+	}
+	<-results
+}
+
+func TestWSKeepalive(t *testing.T) {
+	a, results := newWSTestApp(t)
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	c, _ := dialWS(t, srv, "/small", "")
+	if op, _ := c.recv(); op != opPing {
+		t.Fatalf("got opcode %d, want a ping", op)
+	}
+	c.send(opPong, true, nil)
+	c.sendClose(CloseGoingAway)
+	if code, _ := c.recvClose(); code != CloseGoingAway {
+		t.Fatalf("close code %d", code)
+	}
+	<-results
+}
+
+func TestWSShutdown(t *testing.T) {
+	a, results := newWSTestApp(t)
+	url, result := startTestServer(t, a)
+	srv := &httptest.Server{URL: url}
+
+	c, _ := dialWS(t, srv, "/echo", "")
+	c.send(opText, true, []byte("hello"))
+	c.recv()
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- a.Shutdown(ctx)
+	}()
+
+	if code, _ := c.recvClose(); code != CloseGoingAway {
+		t.Fatalf("close code %d", code)
+	}
+	c.sendClose(CloseGoingAway)
+	<-results
+
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWSSubprotocolPreference(t *testing.T) {
+	a := newTestApp()
+	config := DefaultWSConfig
+	config.Subprotocols = []string{"v2", "v1"}
+	a.Route().WSWithConfig("/ws", config, func(conn *WSConn) error {
+		return conn.Close(CloseNormalClosure, conn.Subprotocol())
+	})
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	c, resp := dialWS(t, srv, "/ws", "Sec-WebSocket-Protocol: v1\r\nSec-WebSocket-Protocol: v3, v2\r\n")
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "v2" {
+		t.Fatalf("subprotocol %q, want the preferred v2", p)
+	}
+	if _, reason := c.recvClose(); reason != "v2" {
+		t.Fatalf("Subprotocol() = %q", reason)
+	}
+	c.sendClose(CloseNormalClosure)
+}
+
+func TestWSCloseWhileReading(t *testing.T) {
+	a := newTestApp()
+	readErr := make(chan error, 1)
+	a.Route().WS("/ws", func(conn *WSConn) error {
+		first := make(chan struct{})
+		go func() {
+			conn.ReadMessage()
+			close(first)
+			_, _, err := conn.ReadMessage()
+			readErr <- err
+		}()
+		<-first
+		return conn.Close(4001, "done")
+	})
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+
+	c, _ := dialWS(t, srv, "/ws", "")
+	c.send(opText, true, []byte("x"))
+	if code, _ := c.recvClose(); code != 4001 {
+		t.Fatalf("close code %d", code)
+	}
+	c.sendClose(4001)
+
+	var closeErr *CloseError
+	if err := <-readErr; !errors.As(err, &closeErr) {
+		t.Fatalf("concurrent ReadMessage returned %v", err)
+	}
+}